@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"aoc2024/internal/intmath"
+)
+
+// runSet sorts one column that may not fit in memory: values are buffered
+// up to a limit, then sorted and spilled to a run file in dir.
+type runSet struct {
+	dir   string
+	name  string
+	limit int
+	buf   []int
+	runs  []string
+}
+
+func (s *runSet) add(v int) error {
+	if len(s.buf) == cap(s.buf) {
+		// Grow by hand so that append never overshoots the limit
+		grown := make([]int, len(s.buf), min(max(2*cap(s.buf), 1024), s.limit))
+		copy(grown, s.buf)
+		s.buf = grown
+	}
+	s.buf = append(s.buf, v)
+	if len(s.buf) >= s.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *runSet) spill() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	sort.Ints(s.buf)
+	path := filepath.Join(s.dir, fmt.Sprintf("%s-%d.run", s.name, len(s.runs)))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	var b [8]byte
+	for _, v := range s.buf {
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		w.Write(b[:])
+	}
+	if err := errors.Join(w.Flush(), file.Close()); err != nil {
+		return err
+	}
+	s.runs = append(s.runs, path)
+	s.buf = s.buf[:0]
+	return nil
+}
+
+type runHead struct {
+	value, run int
+}
+
+type runHeap []runHead
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].value < h[j].value }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(runHead)) }
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// merger is a k-way merge over the sorted runs of a runSet. The first
+// read error stops the merge and is kept in err.
+type merger struct {
+	files   []*os.File
+	readers []*bufio.Reader
+	heads   runHeap
+	err     error
+}
+
+func (s *runSet) merge(bufSize int) (*merger, error) {
+	return openMerger(s.runs, bufSize)
+}
+
+func openMerger(runs []string, bufSize int) (*merger, error) {
+	m := &merger{}
+	for i, path := range runs {
+		file, err := os.Open(path)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+		m.files = append(m.files, file)
+		m.readers = append(m.readers, bufio.NewReaderSize(file, bufSize))
+		m.advance(i)
+	}
+	return m, m.err
+}
+
+// advance pushes the next value of run i onto the heap, if there is one.
+func (m *merger) advance(run int) {
+	var b [8]byte
+	if _, err := io.ReadFull(m.readers[run], b[:]); err == io.EOF {
+		return
+	} else if err != nil {
+		m.err = err
+		return
+	}
+	heap.Push(&m.heads, runHead{int(binary.LittleEndian.Uint64(b[:])), run})
+}
+
+// next returns the smallest remaining value, and false once all runs are
+// exhausted or reading failed.
+func (m *merger) next() (int, bool) {
+	if m.err != nil || m.heads.Len() == 0 {
+		return 0, false
+	}
+	head := heap.Pop(&m.heads).(runHead)
+	m.advance(head.run)
+	return head.value, true
+}
+
+func (m *merger) close() {
+	for _, file := range m.files {
+		file.Close()
+	}
+}
+
+const (
+	// minMergeBuffer is the smallest read buffer worth merging with; below
+	// it the merge spends its time in tiny reads.
+	minMergeBuffer = 4 << 10
+	// maxFanIn caps how many runs of one column are merged at once, so that
+	// the open files of both columns stay far below the descriptor limit.
+	maxFanIn = 128
+)
+
+// reduce merges the runs fanIn at a time into longer ones, pass after
+// pass, until at most fanIn are left for the final merge.
+func (s *runSet) reduce(fanIn, bufSize int) error {
+	for pass := 1; len(s.runs) > fanIn; pass++ {
+		var merged []string
+		for start := 0; start < len(s.runs); start += fanIn {
+			path := filepath.Join(s.dir, fmt.Sprintf("%s-pass%d-%d.run", s.name, pass, len(merged)))
+			if err := mergeRuns(path, s.runs[start:min(start+fanIn, len(s.runs))], bufSize); err != nil {
+				return err
+			}
+			merged = append(merged, path)
+		}
+		s.runs = merged
+	}
+	return nil
+}
+
+// mergeRuns merges runs into a single run at path and removes them.
+func mergeRuns(path string, runs []string, bufSize int) error {
+	m, err := openMerger(runs, bufSize)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		m.close()
+		return err
+	}
+	w := bufio.NewWriterSize(file, bufSize)
+	var b [8]byte
+	for v, ok := m.next(); ok; v, ok = m.next() {
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		w.Write(b[:])
+	}
+	m.close()
+	if err := errors.Join(m.err, w.Flush(), file.Close()); err != nil {
+		return err
+	}
+	for _, run := range runs {
+		os.Remove(run)
+	}
+	return nil
+}
+
+// spillColumns streams the two-column input into the run sets.
+func spillColumns(filename string, left, right *runSet) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid line format: %q (expected 2 columns)", line)
+		}
+		for i, set := range [...]*runSet{left, right} {
+			num, err := strconv.Atoi(parts[i])
+			if err != nil {
+				return fmt.Errorf("error converting number %q on line: %q", parts[i], line)
+			}
+			if err := set.add(num); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.Join(left.spill(), right.spill())
+}
+
+// solveExternal computes both parts while keeping about memLimit bytes of
+// IDs in memory. Each column is sorted into runs on disk, which are merged
+// in passes until few enough are left to read them all at once within the
+// budget. The last runs are then k-way merged twice: once pairing both
+// columns by rank for the distance, and once joining them by ID to count
+// matches for the similarity score.
+func solveExternal(filename string, memLimit int) (distance, similarity int, err error) {
+	// The final merge reads fanIn runs of each column, with a buffer each
+	fanIn := min(memLimit/(2*minMergeBuffer), maxFanIn)
+	if fanIn < 2 {
+		return 0, 0, fmt.Errorf("memory budget of %d bytes is too small, at least %d are needed", memLimit, 4*minMergeBuffer)
+	}
+	bufSize := memLimit / (2 * fanIn)
+	// Half of the budget for each column, 8 bytes per ID
+	limit := memLimit / 16
+
+	dir, err := os.MkdirTemp("", "day01-runs-")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	left := &runSet{dir: dir, name: "left", limit: limit}
+	right := &runSet{dir: dir, name: "right", limit: limit}
+	if err := spillColumns(filename, left, right); err != nil {
+		return 0, 0, err
+	}
+	left.buf, right.buf = nil, nil
+
+	// Merge buffers share the budget the run buffers no longer use
+	if err := errors.Join(left.reduce(fanIn, bufSize), right.reduce(fanIn, bufSize)); err != nil {
+		return 0, 0, err
+	}
+	open := func() (*merger, *merger, error) {
+		l, err := left.merge(bufSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		r, err := right.merge(bufSize)
+		if err != nil {
+			l.close()
+			return nil, nil, err
+		}
+		return l, r, nil
+	}
+
+	leftRuns, rightRuns, err := open()
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		l, okL := leftRuns.next()
+		r, okR := rightRuns.next()
+		if !okL || !okR {
+			break
+		}
+		distance += intmath.Abs(l - r)
+	}
+	leftRuns.close()
+	rightRuns.close()
+	if err := errors.Join(leftRuns.err, rightRuns.err); err != nil {
+		return 0, 0, err
+	}
+
+	leftRuns, rightRuns, err = open()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer leftRuns.close()
+	defer rightRuns.close()
+	l, okL := leftRuns.next()
+	r, okR := rightRuns.next()
+	for okL && okR {
+		switch {
+		case l < r:
+			l, okL = leftRuns.next()
+		case l > r:
+			r, okR = rightRuns.next()
+		default:
+			id, countL, countR := l, 0, 0
+			for ; okL && l == id; l, okL = leftRuns.next() {
+				countL++
+			}
+			for ; okR && r == id; r, okR = rightRuns.next() {
+				countR++
+			}
+			similarity += id * countL * countR
+		}
+	}
+	if err := errors.Join(leftRuns.err, rightRuns.err); err != nil {
+		return 0, 0, err
+	}
+	return distance, similarity, nil
+}
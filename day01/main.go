@@ -2,12 +2,31 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/intmath"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile     = flag.String("input", "input.txt", "puzzle input `file`")
+	reconcileMode = flag.Bool("reconcile", false, "pair lists of unequal length and report unmatched IDs")
+	leftFile      = flag.String("left", "", "read the left list from `file` in -reconcile mode")
+	rightFile     = flag.String("right", "", "read the right list from `file` in -reconcile mode")
+	csvFile       = flag.String("csv", "", "write the -reconcile pairs as CSV to `file` (- for stdout)")
+	external      = flag.Bool("external", false, "sort on disk for inputs that do not fit in memory")
+	memLimit      = flag.Int("mem", 64, "memory budget in `MiB` for -external")
+	matrixMode    = flag.Bool("matrix", false, "compare every pair of columns")
+	numColumns    = flag.Int("columns", 2, "number of columns to read in -matrix mode")
+	metricList    = flag.String("metric", "l1", "comma-separated distance metrics for -matrix: l1, l2, max, jaccard")
+	format        = flag.String("format", "table", "output format for -matrix: table or json")
 )
 
 func readArrays(filename string, numColumns int) ([][]int, error) {
@@ -45,14 +64,6 @@ func readArrays(filename string, numColumns int) ([][]int, error) {
 	return columns, nil
 }
 
-// Helper function to calculate the absolute value of a number
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 func solvePart1(arr1, arr2 []int) int {
 	// Sort both slices
 	sort.Ints(arr1)
@@ -60,7 +71,10 @@ func solvePart1(arr1, arr2 []int) int {
 	// Calculate the absolute differences and sum them
 	acc := 0
 	for i := 0; i < len(arr1) && i < len(arr2); i++ {
-		acc += abs(arr1[i] - arr2[i])
+		acc += intmath.Abs(arr1[i] - arr2[i])
+		if trace.Enabled() {
+			trace.Step("pair", i, "left", arr1[i], "right", arr2[i], "acc", acc)
+		}
 	}
 	return acc
 }
@@ -122,19 +136,69 @@ func solvePart2(arr1, arr2 []int) int {
 	acc := 0
 	for i := 0; i < len(arr1) && i < len(res); i++ {
 		acc += arr1[i] * res[i]
+		if trace.Enabled() {
+			trace.Step("similarity", i, "id", arr1[i], "count", res[i], "acc", acc)
+		}
 	}
 	return acc
 }
 
-func main() {
-	// Read the input arrays
-	columns, err := readArrays("input.txt", 2)
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	columns, err := readArrays(filename, 2)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 	arr1 := columns[0]
 	arr2 := columns[1]
+	return func() any { return solvePart1(arr1, arr2) },
+		func() any { return solvePart2(arr1, arr2) }, nil
+}
+
+func main() {
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeTrace()
+
+	if *reconcileMode {
+		if err := runReconcile(*inputFile, *leftFile, *rightFile, *csvFile); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *matrixMode {
+		if err := runMatrix(os.Stdout, *inputFile, *numColumns, *metricList, *format); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *external {
+		distance, similarity, err := solveExternal(*inputFile, *memLimit<<20)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Part 1:", distance)
+		fmt.Println("Part 2:", similarity)
+		return
+	}
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Println("Part 1:", solvePart1(arr1, arr2))
-	fmt.Println("Part 2:", solvePart2(arr1, arr2))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"aoc2024/internal/intmath"
+)
+
+// Metric compares two sorted columns paired by rank. It returns an int for
+// the metrics that are integers, such as l1, so that they stay exact beyond
+// the 2^53 a float64 holds, and a float64 otherwise.
+type Metric func(a, b []int) any
+
+var metrics = map[string]Metric{
+	// l1 is the total distance of part 1
+	"l1": func(a, b []int) any {
+		acc := 0
+		for i := 0; i < len(a) && i < len(b); i++ {
+			acc += intmath.Abs(a[i] - b[i])
+		}
+		return acc
+	},
+	"l2": func(a, b []int) any {
+		acc := 0.0
+		for i := 0; i < len(a) && i < len(b); i++ {
+			d := float64(a[i] - b[i])
+			acc += d * d
+		}
+		return math.Sqrt(acc)
+	},
+	// max is the largest deviation of any pair
+	"max": func(a, b []int) any {
+		acc := 0
+		for i := 0; i < len(a) && i < len(b); i++ {
+			acc = max(acc, intmath.Abs(a[i]-b[i]))
+		}
+		return acc
+	},
+	// jaccard is the overlap of the distinct IDs of both columns, from 0 to 1
+	"jaccard": func(a, b []int) any {
+		ids := make(map[int]int)
+		for _, id := range a {
+			ids[id] |= 1
+		}
+		for _, id := range b {
+			ids[id] |= 2
+		}
+		if len(ids) == 0 {
+			return 1.0
+		}
+		both := 0
+		for _, sides := range ids {
+			if sides == 3 {
+				both++
+			}
+		}
+		return float64(both) / float64(len(ids))
+	},
+}
+
+// Matrix compares every pair of columns, with one matrix per metric. The
+// jaccard metric is an overlap rather than a distance, so 1 means the same
+// IDs.
+type Matrix struct {
+	Columns    int                `json:"columns"`
+	Distance   map[string][][]any `json:"distance"`
+	Similarity [][]int            `json:"similarity"`
+}
+
+func parseMetrics(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := metrics[name]; !ok {
+			known := make([]string, 0, len(metrics))
+			for k := range metrics {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown metric %q (expected one of %s)", name, strings.Join(known, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func buildMatrix(columns [][]int, names []string) Matrix {
+	n := len(columns)
+	sorted := make([][]int, n)
+	for i, column := range columns {
+		sorted[i] = append([]int(nil), column...)
+		sort.Ints(sorted[i])
+	}
+
+	matrix := Matrix{Columns: n, Distance: make(map[string][][]any)}
+	for _, name := range names {
+		distance := make([][]any, n)
+		for i := range distance {
+			distance[i] = make([]any, n)
+			for j := range distance[i] {
+				distance[i][j] = metrics[name](sorted[i], sorted[j])
+			}
+		}
+		matrix.Distance[name] = distance
+	}
+	matrix.Similarity = make([][]int, n)
+	for i := range matrix.Similarity {
+		matrix.Similarity[i] = make([]int, n)
+		for j := range matrix.Similarity[i] {
+			matrix.Similarity[i][j] = solvePart2(sorted[i], sorted[j])
+		}
+	}
+	return matrix
+}
+
+func (m Matrix) writeJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m)
+}
+
+// formatDistance prints a distance exactly, never in exponent form.
+func formatDistance(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func (m Matrix) writeTable(w io.Writer, names []string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	header := func(title string) {
+		fmt.Fprintf(tw, "%s\t", title)
+		for j := 0; j < m.Columns; j++ {
+			fmt.Fprintf(tw, "col %d\t", j)
+		}
+		fmt.Fprintln(tw)
+	}
+	for _, name := range names {
+		header(name)
+		for i, row := range m.Distance[name] {
+			fmt.Fprintf(tw, "col %d\t", i)
+			for _, v := range row {
+				fmt.Fprintf(tw, "%s\t", formatDistance(v))
+			}
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintln(tw)
+	}
+	header("similarity")
+	for i, row := range m.Similarity {
+		fmt.Fprintf(tw, "col %d\t", i)
+		for _, v := range row {
+			fmt.Fprintf(tw, "%d\t", v)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// runMatrix reads numColumns columns from input and prints the matrix of
+// every column pair for the comma-separated metrics, as a table or JSON.
+func runMatrix(w io.Writer, input string, numColumns int, metricList, format string) error {
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unknown format %q (expected table or json)", format)
+	}
+	names, err := parseMetrics(metricList)
+	if err != nil {
+		return err
+	}
+	columns, err := readArrays(input, numColumns)
+	if err != nil {
+		return err
+	}
+	matrix := buildMatrix(columns, names)
+	if format == "json" {
+		return matrix.writeJSON(w)
+	}
+	return matrix.writeTable(w, names)
+}
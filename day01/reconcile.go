@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"aoc2024/internal/intmath"
+)
+
+// readRaggedArrays reads columns that may have missing entries. A line with
+// fewer than numColumns fields fills the columns from the left, or from the
+// right when the line starts with whitespace, so "  7" only has a right ID.
+func readRaggedArrays(filename string, numColumns int) ([][]int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	defer file.Close()
+
+	columns := make([][]int, numColumns)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) > numColumns {
+			return nil, fmt.Errorf("invalid line format: %q (expected at most %d columns)", line, numColumns)
+		}
+
+		offset := 0
+		if unicode.IsSpace(rune(line[0])) {
+			offset = numColumns - len(parts)
+		}
+		for i, part := range parts {
+			num, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("error converting number %q on line: %q", part, line)
+			}
+			columns[offset+i] = append(columns[offset+i], num)
+		}
+	}
+	return columns, scanner.Err()
+}
+
+// readList reads a single list with one ID per line.
+func readList(filename string) ([]int, error) {
+	columns, err := readRaggedArrays(filename, 1)
+	if err != nil {
+		return nil, err
+	}
+	return columns[0], nil
+}
+
+type Pair struct {
+	left, right, distance int
+}
+
+type Reconciliation struct {
+	pairs          []Pair
+	unmatchedLeft  []int
+	unmatchedRight []int
+	distance       int
+	similarity     int
+}
+
+// reconcile pairs both lists by rank like solvePart1, but keeps the IDs that
+// the shorter list leaves without a partner instead of dropping them.
+func reconcile(left, right []int) Reconciliation {
+	left = append([]int(nil), left...)
+	right = append([]int(nil), right...)
+	sort.Ints(left)
+	sort.Ints(right)
+
+	var rec Reconciliation
+	n := min(len(left), len(right))
+	for i := 0; i < n; i++ {
+		pair := Pair{left[i], right[i], intmath.Abs(left[i] - right[i])}
+		rec.pairs = append(rec.pairs, pair)
+		rec.distance += pair.distance
+	}
+	rec.unmatchedLeft = left[n:]
+	rec.unmatchedRight = right[n:]
+	rec.similarity = solvePart2(left, right)
+	return rec
+}
+
+func (rec Reconciliation) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"left", "right", "distance"})
+	for _, pair := range rec.pairs {
+		writer.Write([]string{strconv.Itoa(pair.left), strconv.Itoa(pair.right), strconv.Itoa(pair.distance)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (rec Reconciliation) print(w io.Writer) {
+	fmt.Fprintln(w, "Pairs:", len(rec.pairs))
+	fmt.Fprintln(w, "Total distance:", rec.distance)
+	fmt.Fprintln(w, "Similarity score:", rec.similarity)
+	fmt.Fprintf(w, "Unmatched left (%d): %v\n", len(rec.unmatchedLeft), rec.unmatchedLeft)
+	fmt.Fprintf(w, "Unmatched right (%d): %v\n", len(rec.unmatchedRight), rec.unmatchedRight)
+}
+
+// runReconcile reads either the -left and -right list files or a ragged
+// two-column input, and prints the reconciliation, writing the pairs as CSV
+// to csvFile ("-" for stdout) when it is set.
+func runReconcile(input, leftFile, rightFile, csvFile string) error {
+	var left, right []int
+	if leftFile != "" || rightFile != "" {
+		if leftFile == "" || rightFile == "" {
+			return fmt.Errorf("-left and -right must be given together")
+		}
+		var err error
+		if left, err = readList(leftFile); err != nil {
+			return err
+		}
+		if right, err = readList(rightFile); err != nil {
+			return err
+		}
+	} else {
+		columns, err := readRaggedArrays(input, 2)
+		if err != nil {
+			return err
+		}
+		left, right = columns[0], columns[1]
+	}
+
+	rec := reconcile(left, right)
+	switch csvFile {
+	case "":
+	case "-":
+		if err := rec.writeCSV(os.Stdout); err != nil {
+			return err
+		}
+	default:
+		file, err := os.Create(csvFile)
+		if err != nil {
+			return err
+		}
+		if err := rec.writeCSV(file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	rec.print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Diagnosis explains the safety of one report. Removed lists the levels the
+// dampener takes out to rescue an unsafe report, and Rescued is false when
+// it cannot.
+type Diagnosis struct {
+	Report    int        `json:"report"`
+	Levels    []int      `json:"levels"`
+	Safe      bool       `json:"safe"`
+	Violation *Violation `json:"violation,omitempty"`
+	Rescued   bool       `json:"rescued"`
+	Removed   []int      `json:"removed,omitempty"`
+}
+
+func diagnoseReports(reports [][]int, rule Rule, k int) []Diagnosis {
+	diagnoses := make([]Diagnosis, len(reports))
+	for i, report := range reports {
+		d := Diagnosis{Report: i, Levels: report, Violation: rule.check(report)}
+		d.Safe = d.Violation == nil
+		if !d.Safe {
+			d.Removed, d.Rescued = dampen(report, rule, k)
+		}
+		diagnoses[i] = d
+	}
+	return diagnoses
+}
+
+func writeDiagnoses(w io.Writer, diagnoses []Diagnosis, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diagnoses)
+	case "text":
+		for _, d := range diagnoses {
+			fmt.Fprintf(w, "report %d %v: ", d.Report, d.Levels)
+			switch {
+			case d.Safe:
+				fmt.Fprintln(w, "safe")
+			case d.Rescued:
+				fmt.Fprintf(w, "unsafe, %s between levels %d and %d (%d -> %d); dampener removes levels %v\n",
+					d.Violation.Reason, d.Violation.Index, d.Violation.Index+1, d.Violation.From, d.Violation.To, d.Removed)
+			default:
+				fmt.Fprintf(w, "unsafe, %s between levels %d and %d (%d -> %d); dampener cannot rescue it\n",
+					d.Violation.Reason, d.Violation.Index, d.Violation.Index+1, d.Violation.From, d.Violation.To)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runDiagnose prints the diagnosis of every report of filename.
+func runDiagnose(w io.Writer, filename string) error {
+	rule, err := flagRule()
+	if err != nil {
+		return err
+	}
+	reports, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	return writeDiagnoses(w, diagnoseReports(reports, rule, *tolerance), *format)
+}
@@ -3,10 +3,26 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/intmath"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+	tolerance = flag.Int("tolerance", 1, "number of bad levels the Problem Dampener may remove")
+	minStep   = flag.Int("min-step", defaultRule.MinStep, "smallest safe step between adjacent levels")
+	maxStep   = flag.Int("max-step", defaultRule.MaxStep, "largest safe step between adjacent levels")
+	plateaus  = flag.Bool("plateaus", false, "allow adjacent levels to be equal")
+	direction = flag.String("direction", "any", "required direction of the levels: any, increasing or decreasing")
+	diagnose  = flag.Bool("diagnose", false, "explain the safety of every report")
+	format    = flag.String("format", "text", "output format for -diagnose: text or json")
 )
 
 func parseFile(filename string) ([][]int, error) {
@@ -36,63 +52,260 @@ func parseFile(filename string) ([][]int, error) {
 	return result, scanner.Err()
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
+type Direction int
+
+const (
+	AnyDirection Direction = iota
+	Increasing
+	Decreasing
+)
+
+func parseDirection(name string) (Direction, error) {
+	switch name {
+	case "any":
+		return AnyDirection, nil
+	case "increasing":
+		return Increasing, nil
+	case "decreasing":
+		return Decreasing, nil
+	}
+	return AnyDirection, fmt.Errorf("unknown direction %q (expected any, increasing or decreasing)", name)
+}
+
+func (d Direction) String() string {
+	return [...]string{"any", "increasing", "decreasing"}[d]
+}
+
+// signs returns the directions a report may take, as the sign of its steps.
+func (d Direction) signs() []int {
+	switch d {
+	case Increasing:
+		return []int{1}
+	case Decreasing:
+		return []int{-1}
+	}
+	return []int{1, -1}
+}
+
+// Rule is a safety rule: every step between adjacent levels moves in the
+// same direction by MinStep to MaxStep, or stays put if AllowPlateaus.
+type Rule struct {
+	MinStep, MaxStep int
+	AllowPlateaus    bool
+	Direction        Direction
+}
+
+var defaultRule = Rule{MinStep: 1, MaxStep: 3}
+
+// validate checks the step range. Equal levels are only ever allowed by
+// AllowPlateaus, so MinStep must be at least 1.
+func (rule Rule) validate() error {
+	if rule.MinStep < 1 {
+		return fmt.Errorf("invalid minimum step %d, must be at least 1 (allow equal levels with -plateaus)", rule.MinStep)
+	}
+	if rule.MaxStep < rule.MinStep {
+		return fmt.Errorf("invalid step range %d to %d", rule.MinStep, rule.MaxStep)
+	}
+	return nil
+}
+
+// validStep reports whether a step from one level to the next is safe in
+// the direction given by sign.
+func (rule Rule) validStep(from, to, sign int) bool {
+	diff := (to - from) * sign
+	if diff == 0 {
+		return rule.AllowPlateaus
 	}
-	return x
+	return diff >= rule.MinStep && diff <= rule.MaxStep
 }
-func isValid(slice []int) bool {
-	if (len(slice) <= 2) && (slice[0] != slice[1]) {
-		return true
+
+const (
+	ReasonPlateau         = "plateau"
+	ReasonStepTooSmall    = "step too small"
+	ReasonStepTooLarge    = "step too large"
+	ReasonDirectionChange = "direction change"
+	ReasonWrongDirection  = "wrong direction"
+)
+
+// Violation is the first unsafe step of a report, between the levels at
+// Index and Index+1.
+type Violation struct {
+	Index  int    `json:"index"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Reason string `json:"reason"`
+}
+
+// check returns the first step of report that breaks the rule, or nil if
+// the report is safe. Reports with fewer than two levels are always safe.
+func (rule Rule) check(report []int) *Violation {
+	sign := 0
+	if rule.Direction != AnyDirection {
+		sign = rule.Direction.signs()[0]
 	}
-	increasing := slice[1] > slice[0]
-	for i := 1; i < len(slice); i++ {
-		diff := abs(slice[i] - slice[i-1])
-		if (diff < 1) || (diff > 3) || (increasing && slice[i] <= slice[i-1]) || (!increasing && slice[i] >= slice[i-1]) {
-			return false
+	for i := 1; i < len(report); i++ {
+		diff := report[i] - report[i-1]
+		violation := func(reason string) *Violation {
+			return &Violation{i - 1, report[i-1], report[i], reason}
+		}
+		switch {
+		case diff == 0 && rule.AllowPlateaus:
+			continue
+		case diff == 0:
+			return violation(ReasonPlateau)
+		case sign == 0:
+			sign = diff / intmath.Abs(diff)
+		case diff*sign < 0 && rule.Direction == AnyDirection:
+			return violation(ReasonDirectionChange)
+		case diff*sign < 0:
+			return violation(ReasonWrongDirection)
+		}
+		if step := intmath.Abs(diff); step < rule.MinStep {
+			return violation(ReasonStepTooSmall)
+		} else if step > rule.MaxStep {
+			return violation(ReasonStepTooLarge)
 		}
 	}
-	return true
+	return nil
+}
+
+func isValid(report []int, rule Rule) bool {
+	return rule.check(report) == nil
 }
 
-func solvePart1(slices [][]int) int {
+func solvePart1(slices [][]int, rule Rule) int {
 	countValid := 0
-	for _, slice := range slices {
-		if isValid(slice) {
+	for i, slice := range slices {
+		valid := isValid(slice, rule)
+		if valid {
 			countValid++
 		}
+		if trace.Enabled() {
+			trace.Step("report", i, "levels", len(slice), "safe", valid)
+		}
 	}
 	return countValid
 }
 
-func solvePart2(slices [][]int) int {
-	countValid := 0
-	for _, slice := range slices {
-		if isValid(slice) {
-			countValid++
-		} else {
-			for i := 0; i < len(slice); i++ {
-				var modSlice []int
-				modSlice = append(modSlice, slice[:i]...)
-				modSlice = append(modSlice, slice[i+1:]...)
-				if isValid(modSlice) {
-					countValid++
-					break
+// dampen reports whether removing at most k levels makes the report safe,
+// and returns the indices of the fewest levels to remove. For each direction
+// it finds the cheapest chain of kept levels: best[i] is the number of levels
+// removed before i when i is kept, and since at most k levels can be skipped
+// in a row only the k+1 levels before i can precede it, which makes the
+// check O(n·k) without copying the report.
+func dampen(report []int, rule Rule, k int) (removed []int, ok bool) {
+	n := len(report)
+	if n <= 1 {
+		return nil, true
+	}
+	best := make([]int, n)
+	prev := make([]int, n)
+	for _, sign := range rule.Direction.signs() {
+		for i := range report {
+			best[i], prev[i] = i, -1 // drop every level before i
+			for j := max(i-k-1, 0); j < i; j++ {
+				if best[j] > k || !rule.validStep(report[j], report[i], sign) {
+					continue
+				}
+				if cost := best[j] + i - j - 1; cost < best[i] {
+					best[i], prev[i] = cost, j
+				}
+			}
+		}
+
+		last := -1
+		for i := max(n-k-1, 0); i < n; i++ {
+			if cost := best[i] + n - 1 - i; cost <= k && (last == -1 || cost < best[last]+n-1-last) {
+				last = i
+			}
+		}
+		if last == -1 {
+			continue
+		}
+		if removed == nil || best[last]+n-1-last < len(removed) {
+			kept := make([]bool, n)
+			for i := last; i >= 0; i = prev[i] {
+				kept[i] = true
+			}
+			removed = []int{}
+			for i, keep := range kept {
+				if !keep {
+					removed = append(removed, i)
 				}
 			}
+			ok = true
+		}
+	}
+	return removed, ok
+}
+
+func solvePart2(reports [][]int, rule Rule, k int) int {
+	countValid := 0
+	for n, report := range reports {
+		removed, ok := dampen(report, rule, k)
+		if ok {
+			countValid++
+		}
+		if trace.Enabled() {
+			trace.Step("report", n, "levels", len(report), "safe", ok, "removed", removed)
 		}
 	}
 	return countValid
 }
 
+// flagRule builds the safety rule from the command line.
+func flagRule() (Rule, error) {
+	dir, err := parseDirection(*direction)
+	if err != nil {
+		return Rule{}, err
+	}
+	rule := Rule{MinStep: *minStep, MaxStep: *maxStep, AllowPlateaus: *plateaus, Direction: dir}
+	return rule, rule.validate()
+}
+
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	rule, err := flagRule()
+	if err != nil {
+		return nil, nil, err
+	}
+	slices, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(slices, rule) },
+		func() any { return solvePart2(slices, rule, *tolerance) }, nil
+}
+
 func main() {
-	slices, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if *diagnose {
+		if err := runDiagnose(os.Stdout, *inputFile); err != nil {
+			fmt.Printf("Error diagnosing reports: %v\n", err)
+		}
+		return
+	}
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(slices))
-	fmt.Println("Part 2:", solvePart2(slices))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
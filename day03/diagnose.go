@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// Diagnostics lists the near-misses of an input with a count per reason.
+type Diagnostics struct {
+	NearMisses []NearMiss     `json:"near_misses"`
+	Counts     map[string]int `json:"counts"`
+}
+
+// findNearMisses scans r with the lexer of reg and collects every near-miss.
+func findNearMisses(reg *Registry, r io.Reader) (Diagnostics, error) {
+	diag := Diagnostics{NearMisses: []NearMiss{}, Counts: make(map[string]int)}
+	lexer := NewLexer(reg, r)
+	lexer.OnNearMiss = func(miss NearMiss) {
+		diag.NearMisses = append(diag.NearMisses, miss)
+		diag.Counts[miss.Reason]++
+	}
+	for {
+		if _, ok := lexer.Next(); !ok {
+			break
+		}
+	}
+	return diag, lexer.Err()
+}
+
+func (diag Diagnostics) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, miss := range diag.NearMisses {
+		fmt.Fprintf(tw, "%d\t%q\t%s\n", miss.Offset, miss.Text, miss.Reason)
+	}
+	fmt.Fprintln(tw)
+
+	reasons := make([]string, 0, len(diag.Counts))
+	for reason := range diag.Counts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if diag.Counts[reasons[i]] != diag.Counts[reasons[j]] {
+			return diag.Counts[reasons[i]] > diag.Counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	for _, reason := range reasons {
+		fmt.Fprintf(tw, "%s\t%d\n", reason, diag.Counts[reason])
+	}
+	return tw.Flush()
+}
+
+// runDiagnose prints the near-misses of every part 2 instruction in
+// filename, as text or JSON.
+func runDiagnose(w io.Writer, filename, format string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	diag, err := findNearMisses(part2Registry, file)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "text":
+		return diag.writeText(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diag)
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"aoc2024/internal/trace"
+)
+
+// Machine is the state the instructions act on.
+type Machine struct {
+	Enabled bool
+	Acc     int
+}
+
+// Exec records one instruction run by the interpreter. Gated instructions
+// that were skipped have Enabled false.
+type Exec struct {
+	Instruction
+	Enabled bool
+	Acc     int
+}
+
+// run interprets every instruction of reg found in r and returns the
+// accumulator. onExec, when not nil, is called after each instruction.
+func run(reg *Registry, r io.Reader, onExec func(Exec)) (int, error) {
+	m := Machine{Enabled: true}
+	lexer := NewLexer(reg, r)
+	for i := 0; ; i++ {
+		in, ok := lexer.Next()
+		if !ok {
+			break
+		}
+		enabled := m.Enabled || !in.Op.Gated
+		if enabled {
+			in.Op.Exec(&m, in.Args)
+		}
+		if onExec != nil {
+			onExec(Exec{in, enabled, m.Acc})
+		}
+		if trace.Enabled() {
+			trace.Step("instruction", i, "offset", in.Offset, "text", in.Text, "enabled", enabled, "acc", m.Acc)
+		}
+	}
+	return m.Acc, lexer.Err()
+}
+
+// runFile streams filename through run.
+func runFile(reg *Registry, filename string, onExec func(Exec)) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return run(reg, file, onExec)
+}
+
+func printExec(w io.Writer, e Exec) {
+	status := "skipped"
+	if e.Enabled {
+		status = "run"
+	}
+	fmt.Fprintf(w, "%10d  %-12s %-7s acc=%d\n", e.Offset, e.Text, status, e.Acc)
+}
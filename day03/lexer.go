@@ -0,0 +1,228 @@
+package main
+
+import "io"
+
+const (
+	// maxDigits is the most digits an instruction argument may have.
+	maxDigits = 3
+	// chunkSize is how much input the lexer reads at a time.
+	chunkSize = 64 << 10
+)
+
+// Opcode describes one instruction of the memory language: its name, the
+// number of arguments it takes and its effect on the machine.
+type Opcode struct {
+	Name  string
+	Arity int
+	// Gated instructions only run while the machine is enabled.
+	Gated bool
+	Exec  func(m *Machine, args []int)
+}
+
+var (
+	Mul = &Opcode{Name: "mul", Arity: 2, Gated: true, Exec: func(m *Machine, args []int) {
+		m.Acc += args[0] * args[1]
+	}}
+	Do   = &Opcode{Name: "do", Exec: func(m *Machine, args []int) { m.Enabled = true }}
+	Dont = &Opcode{Name: "don't", Exec: func(m *Machine, args []int) { m.Enabled = false }}
+)
+
+// Registry is the set of instructions the lexer recognizes.
+type Registry struct {
+	byFirst map[byte][]*Opcode
+	// maxLen bounds the length of any instruction
+	maxLen int
+}
+
+func NewRegistry(opcodes ...*Opcode) *Registry {
+	r := &Registry{byFirst: make(map[byte][]*Opcode)}
+	for _, op := range opcodes {
+		r.Register(op)
+	}
+	return r
+}
+
+// Register adds op to the registry. Names must not contain '('.
+func (r *Registry) Register(op *Opcode) {
+	r.byFirst[op.Name[0]] = append(r.byFirst[op.Name[0]], op)
+	r.maxLen = max(r.maxLen, len(op.Name)+2+op.Arity*(maxDigits+1))
+}
+
+// Instruction is a well-formed instruction found at Offset in the input.
+type Instruction struct {
+	Op     *Opcode
+	Args   []int
+	Offset int
+	Text   string
+}
+
+// Lexer finds the well-formed instructions of a registry amid garbage. Like
+// the regexes it replaces, matches never overlap and a failed match resumes
+// at the next byte. The input is read in chunks, keeping enough of the
+// previous chunk to match instructions that straddle a boundary, so memory
+// stays constant however long the input and its lines are.
+type Lexer struct {
+	reg *Registry
+	r   io.Reader
+	buf []byte
+	// pos is the next byte of buf to scan, and offset the input offset of buf[0]
+	pos, offset int
+	eof         bool
+	err         error
+
+	// OnNearMiss, when set, is called for every fragment that starts like
+	// an instruction but is malformed. Scanning resumes at the next byte.
+	OnNearMiss func(NearMiss)
+}
+
+// NearMiss is a malformed instruction found at Offset in the input. When
+// several instructions could start there, it is the one that got furthest.
+type NearMiss struct {
+	Offset      int    `json:"offset"`
+	Text        string `json:"text"`
+	Instruction string `json:"instruction"`
+	Reason      string `json:"reason"`
+}
+
+func NewLexer(reg *Registry, r io.Reader) *Lexer {
+	return &Lexer{reg: reg, r: r, buf: make([]byte, 0, max(chunkSize, 2*reg.maxLen))}
+}
+
+// fill reads the next chunk once fewer than maxLen bytes are left to scan,
+// dropping the bytes already scanned.
+func (l *Lexer) fill() {
+	for !l.eof && len(l.buf)-l.pos < l.reg.maxLen {
+		kept := copy(l.buf, l.buf[l.pos:])
+		l.offset += l.pos
+		l.buf, l.pos = l.buf[:kept], 0
+
+		n, err := l.r.Read(l.buf[kept:cap(l.buf)])
+		l.buf = l.buf[:kept+n]
+		if err != nil {
+			l.eof = true
+			if err != io.EOF {
+				l.err = err
+			}
+		}
+	}
+}
+
+// Next returns the next instruction, and false at the end of the input or
+// on a read error, which Err then returns.
+func (l *Lexer) Next() (Instruction, bool) {
+	for ; ; l.pos++ {
+		l.fill()
+		if l.pos >= len(l.buf) {
+			return Instruction{}, false
+		}
+		var miss *NearMiss
+		for _, op := range l.reg.byFirst[l.buf[l.pos]] {
+			args, n, reason, named := matchAt(op, l.buf[l.pos:])
+			switch {
+			case named && reason == "":
+				in := Instruction{op, args, l.offset + l.pos, string(l.buf[l.pos : l.pos+n])}
+				l.pos += n
+				return in, true
+			case named && l.OnNearMiss != nil && (miss == nil || n > len(miss.Text)):
+				miss = &NearMiss{l.offset + l.pos, string(l.buf[l.pos : l.pos+n]), op.Name, reason}
+			}
+		}
+		if miss != nil {
+			l.OnNearMiss(*miss)
+		}
+	}
+}
+
+// Err returns the first read error of the lexer.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// Reasons a fragment that starts with an instruction name is rejected.
+const (
+	ReasonTruncated     = "truncated"
+	ReasonNoParen       = "missing '('"
+	ReasonWhitespace    = "whitespace"
+	ReasonNotANumber    = "argument is not a number"
+	ReasonTooManyDigits = "too many digits"
+	ReasonSeparator     = "bad separator"
+	ReasonTooFewArgs    = "too few arguments"
+	ReasonTooManyArgs   = "too many arguments"
+	ReasonNoCloseParen  = "missing ')'"
+)
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isWordByte reports whether c can continue a name, in which case the name
+// is only part of a longer word, like "do" in "don't" or "dog".
+func isWordByte(c byte) bool {
+	return c == '_' || c == '\'' || isDigit(c) || (c|0x20) >= 'a' && (c|0x20) <= 'z'
+}
+
+// matchAt matches op(arg,...) at the start of b and returns its arguments
+// and length. When b starts with the name of op but the rest is malformed,
+// it returns why instead, with n covering the fragment up to the offending
+// byte. named is false when b does not start with the name at all.
+func matchAt(op *Opcode, b []byte) (args []int, n int, reason string, named bool) {
+	if len(b) < len(op.Name) || string(b[:len(op.Name)]) != op.Name {
+		return nil, 0, "", false
+	}
+	n = len(op.Name)
+	reject := func(reason string) ([]int, int, string, bool) {
+		return nil, min(n+1, len(b)), reason, true
+	}
+	// unexpected returns why b[n] is not the expected byte
+	unexpected := func(expected string) ([]int, int, string, bool) {
+		switch {
+		case n >= len(b):
+			return reject(ReasonTruncated)
+		case isSpace(b[n]):
+			return reject(ReasonWhitespace)
+		}
+		return reject(expected)
+	}
+
+	if n < len(b) && isWordByte(b[n]) {
+		return nil, 0, "", false
+	}
+	if n >= len(b) || b[n] != '(' {
+		return unexpected(ReasonNoParen)
+	}
+	n++
+	for i := 0; i < op.Arity; i++ {
+		if i > 0 {
+			if n < len(b) && b[n] == ')' {
+				return reject(ReasonTooFewArgs)
+			}
+			if n >= len(b) || b[n] != ',' {
+				return unexpected(ReasonSeparator)
+			}
+			n++
+		}
+		num, digits := 0, 0
+		for ; n < len(b) && digits < maxDigits && isDigit(b[n]); n, digits = n+1, digits+1 {
+			num = num*10 + int(b[n]-'0')
+		}
+		if digits == 0 {
+			if n < len(b) && (b[n] == ',' || b[n] == ')') {
+				return reject(ReasonTooFewArgs)
+			}
+			return unexpected(ReasonNotANumber)
+		}
+		if n < len(b) && isDigit(b[n]) {
+			return reject(ReasonTooManyDigits)
+		}
+		args = append(args, num)
+	}
+	if n < len(b) && b[n] == ',' || op.Arity == 0 && n < len(b) && isDigit(b[n]) {
+		return reject(ReasonTooManyArgs)
+	}
+	if n >= len(b) || b[n] != ')' {
+		return unexpected(ReasonNoCloseParen)
+	}
+	return args, n + 1, "", true
+}
@@ -2,84 +2,106 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
-	"strconv"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+	steps     = flag.Bool("steps", false, "print every part 2 instruction with its offset and whether it ran")
+	diagnose  = flag.Bool("diagnose", false, "report every malformed instruction and why it was rejected")
+	format    = flag.String("format", "text", "output format for -diagnose: text or json")
+)
+
+var (
+	part1Registry = NewRegistry(Mul)
+	part2Registry = NewRegistry(Mul, Do, Dont)
 )
 
-func parseFile(filename string) ([]string, error) {
+func solvePart1(memory io.Reader) (int, error) {
+	return run(part1Registry, memory, nil)
+}
+
+func solvePart2(memory io.Reader) (int, error) {
+	return run(part2Registry, memory, nil)
+}
+
+// solvers checks that filename can be read and returns the solvers of both
+// parts, which each stream the file. The memory dump is never held whole.
+func solvers(filename string) (part1, part2 func() any, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer file.Close()
+	file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text()) // Append each line to the slice
+	stream := func(solve func(io.Reader) (int, error)) func() any {
+		return func() any {
+			file, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			acc, err := solve(file)
+			if err != nil {
+				return err
+			}
+			return acc
+		}
 	}
-
-	return lines, scanner.Err()
+	return stream(solvePart1), stream(solvePart2), nil
 }
 
-func solvePart1(lines []string) int {
-	pattern := `mul\((\d{1,3}),(\d{1,3})\)`
-	regex := regexp.MustCompile(pattern)
-	acc := 0
-	for _, line := range lines {
-		matches := regex.FindAllStringSubmatch(line, -1)
-		for _, match := range matches {
-			num1, _ := strconv.Atoi(match[1])
-			num2, _ := strconv.Atoi(match[2])
-			acc += num1 * num2
+func main() {
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if *steps {
+		acc, err := runFile(part2Registry, *inputFile, func(e Exec) { printExec(os.Stdout, e) })
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			return
 		}
+		fmt.Println("Part 2:", acc)
+		return
 	}
-	return acc
-}
 
-func solvePart2(lines []string) int {
-	doDontRegex := regexp.MustCompile(`do\(\)|don't\(\)`)
-	mulRegex := regexp.MustCompile(`mul\((\d{1,3}),(\d{1,3})\)`)
-	acc := 0
-	lastCommand := "do()"
-	for _, line := range lines {
-		segments := doDontRegex.Split(line, -1)
-		triggers := []string{lastCommand}
-		triggersExtracted := doDontRegex.FindAllString(line, -1)
-		triggers = append(triggers, triggersExtracted...)
-		for i, segment := range segments {
-			if triggers[i] == "do()" {
-				matches := mulRegex.FindAllStringSubmatch(segment, -1)
-				for _, match := range matches {
-					num1, err1 := strconv.Atoi(match[1])
-					num2, err2 := strconv.Atoi(match[2])
-					if err1 == nil && err2 == nil {
-						acc += num1 * num2
-					}
-				}
-			}
+	if *diagnose {
+		if err := runDiagnose(os.Stdout, *inputFile, *format); err != nil {
+			fmt.Printf("Error diagnosing memory: %v\n", err)
 		}
-		lastCommand = triggers[len(triggers)-1]
+		return
 	}
-	return acc
-}
 
-func main() {
-	lines, err := parseFile("input.txt")
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(lines))
-
-	// lines2, err := parseFile("input.txt")
-	// if err != nil {
-	// 	fmt.Printf("Error reading file: %v\n", err)
-	// 	return
-	// }
-	fmt.Println("Part 2:", solvePart2(lines))
+	for i, part := range []func() any{part1, part2} {
+		answer := part()
+		if err, ok := answer.(error); ok {
+			fmt.Printf("Error reading file: %v\n", err)
+			return
+		}
+		fmt.Printf("Part %d: %v\n", i+1, answer)
+	}
 }
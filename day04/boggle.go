@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// trieNode is a node of a prefix tree over the dictionary. word is set on
+// the node ending a word.
+type trieNode struct {
+	children map[rune]*trieNode
+	word     string
+}
+
+func (n *trieNode) insert(word string) {
+	for _, ch := range word {
+		child, ok := n.children[ch]
+		if !ok {
+			child = &trieNode{children: make(map[rune]*trieNode)}
+			n.children[ch] = child
+		}
+		n = child
+	}
+	n.word = word
+}
+
+func readDictionary(filename string) (*trieNode, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root := &trieNode{children: make(map[rune]*trieNode)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			root.insert(word)
+		}
+	}
+	return root, scanner.Err()
+}
+
+// PathMatch is a word spelled by a path of adjacent cells.
+type PathMatch struct {
+	Word string   `json:"word"`
+	Path [][2]int `json:"path"`
+}
+
+// SearchPaths finds the words of the dictionary spelled by any path of
+// adjacent cells along dirs, using each cell at most once per word. Every
+// word is reported once, with the first path found. The trie prunes the
+// search as soon as a path spells no dictionary prefix.
+func (g Grid) SearchPaths(root *trieNode, dirs []Direction) []PathMatch {
+	var found []PathMatch
+	reported := make(map[*trieNode]bool) // the trie is left untouched for reuse
+	visited := make([][]bool, g.height)
+	for r := range visited {
+		visited[r] = make([]bool, g.width)
+	}
+	var path [][2]int
+
+	var walk func(n *trieNode, r, c int)
+	walk = func(n *trieNode, r, c int) {
+		n = n.children[g.data[r][c]]
+		if n == nil {
+			return
+		}
+		visited[r][c] = true
+		path = append(path, [2]int{r, c})
+		if n.word != "" && !reported[n] {
+			found = append(found, PathMatch{n.word, append([][2]int(nil), path...)})
+			reported[n] = true
+		}
+		for _, dir := range dirs {
+			if nr, nc := dir.Move(r, c); g.IsValidPos(nr, nc) && !visited[nr][nc] {
+				walk(n, nr, nc)
+			}
+		}
+		path = path[:len(path)-1]
+		visited[r][c] = false
+	}
+
+	for r := 0; r < g.height; r++ {
+		for c := 0; c < g.width; c++ {
+			walk(root, r, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Word < found[j].Word })
+	return found
+}
+
+func writePathMatches(w io.Writer, found []PathMatch, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(found)
+	case "text":
+		for _, m := range found {
+			cells := make([]string, len(m.Path))
+			for i, cell := range m.Path {
+				cells[i] = fmt.Sprintf("%d,%d", cell[0], cell[1])
+			}
+			fmt.Fprintf(w, "%s: %s\n", m.Word, strings.Join(cells, " "))
+		}
+		fmt.Fprintf(w, "%d words found\n", len(found))
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runBoggle finds the words of dictFile along 4- or 8-connected paths of
+// the grid of filename.
+func runBoggle(w io.Writer, filename, dictFile string, connectivity int, format string) error {
+	var dirs []Direction
+	switch connectivity {
+	case 4:
+		dirs = []Direction{NORTH, EAST, SOUTH, WEST}
+	case 8:
+		dirs = AllDirections
+	default:
+		return fmt.Errorf("invalid connectivity %d (expected 4 or 8)", connectivity)
+	}
+	root, err := readDictionary(dictFile)
+	if err != nil {
+		return err
+	}
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	grid := Grid{data: data, width: width, height: height}
+	return writePathMatches(w, grid.SearchPaths(root, dirs), format)
+}
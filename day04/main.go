@@ -3,8 +3,28 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile  = flag.String("input", "input.txt", "puzzle input `file`")
+	words      = flag.String("words", "", "comma-separated `words` to search for instead of solving")
+	directions = flag.String("directions", "all", "search `directions`: all, orthogonal, diagonal or a list such as E,SE,S")
+	wrap       = flag.Bool("wrap", false, "let words wrap around the edges of the grid")
+	overlap    = flag.Bool("overlap", true, "let matches share cells")
+	pattern    = flag.String("pattern", "", "template `file` to match in every orientation instead of solving")
+	fixed      = flag.Bool("fixed", false, "match the -pattern template only as written, without rotations or reflections")
+	dict       = flag.String("dict", "", "dictionary `file` of words to find along bending paths instead of solving")
+	connect    = flag.Int("connect", 8, "cell connectivity of -dict paths: 4 or 8")
+	format     = flag.String("format", "text", "output format for -words, -pattern and -dict: text or json")
+	render     = flag.String("render", "", "print the grid highlighting the xmas or x-mas matches instead of solving")
+	colorBy    = flag.String("color", "none", "colouring of -render: none, match or direction")
+	pngFile    = flag.String("png", "", "write the -render picture to a PNG `file` instead of printing it")
 )
 
 func parseFile(filename string) ([][]rune, int, int, error) {
@@ -91,89 +111,97 @@ func (d Direction) Move(r, c int) (int, int) {
 	}
 }
 
-func countXmas(grid Grid, rStart, cStart, ix int, xmas []rune, dir Direction) int {
-	if ix >= len(xmas) {
-		return 1
-	}
-	r, c := dir.Move(rStart, cStart)
-	if grid.IsValidPos(r, c) && (grid.data[r][c] == xmas[ix]) {
-		return countXmas(grid, r, c, ix+1, xmas, dir)
-	}
-	return 0
-}
-
 func solvePart1(data [][]rune, width, height int) int {
 	grid := Grid{data: data, width: width, height: height}
-	res := 0
-	xmas := []rune("MAS")
-	allDirections := [...]Direction{NORTH, NORTHEAST, EAST, SOUTHEAST, SOUTH, SOUTHWEST, WEST, NORTHWEST}
-	for r := 0; r < grid.height; r++ {
-		for c := 0; c < grid.width; c++ {
-			if grid.data[r][c] == 'X' {
-				for _, dir := range allDirections {
-					res += countXmas(grid, r, c, 0, xmas, dir)
-				}
-
-			}
+	matches, counts := grid.Search([]string{"XMAS"}, SearchOptions{Directions: AllDirections, Overlap: true})
+	for i, m := range matches {
+		if trace.Enabled() {
+			trace.Step("match", i, "r", m.Row, "c", m.Col, "dir", m.Dir)
 		}
 	}
-	return res
+	return counts["XMAS"]
 }
 
+// xmasTemplate is one orientation of the X-shaped MAS of part 2.
+const xmasTemplate = `M.S
+.A.
+M.S`
+
 func solvePart2(data [][]rune, width, height int) int {
-	patterns := [][]rune{
-		{
-			'M', '.', 'M',
-			'.', 'A', '.',
-			'S', '.', 'S',
-		},
-		{
-			'M', '.', 'S',
-			'.', 'A', '.',
-			'M', '.', 'S',
-		},
-		{
-			'S', '.', 'M',
-			'.', 'A', '.',
-			'S', '.', 'M',
-		},
-		{
-			'S', '.', 'S',
-			'.', 'A', '.',
-			'M', '.', 'M',
-		},
+	t, err := parseTemplate(xmasTemplate)
+	if err != nil {
+		panic(err)
 	}
-	pSize := 3
-	res := 0
-	for r := 0; r <= height-pSize; r++ {
-		for c := 0; c <= width-pSize; c++ {
-			for _, pattern := range patterns {
-				match := true
-				for p := 0; p < len(pattern); p++ {
-					if pattern[p] == '.' {
-						continue
-					}
-					if pattern[p] != data[r+p/pSize][c+(p%pSize)] {
-						match = false
-						break
-					}
-				}
-				if match {
-					res++
-				}
-			}
+	matches := NewMatcher(Grid{data: data, width: width, height: height}).Find(t.Orientations())
+	for i, m := range matches {
+		if trace.Enabled() {
+			trace.Step("match", i, "r", m.Row, "c", m.Col, "orientation", m.Orientation)
 		}
 	}
-	return res
+	return len(matches)
+}
+
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data, width, height) },
+		func() any { return solvePart2(data, width, height) }, nil
 }
 
 func main() {
-	data, width, height, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if *words != "" {
+		opts := SearchOptions{Wrap: *wrap, Overlap: *overlap}
+		if err := runSearch(os.Stdout, *inputFile, *words, *directions, opts, *format); err != nil {
+			fmt.Printf("Error searching words: %v\n", err)
+		}
+		return
+	}
+
+	if *pattern != "" {
+		if err := runPattern(os.Stdout, *inputFile, *pattern, *fixed, *format); err != nil {
+			fmt.Printf("Error matching pattern: %v\n", err)
+		}
+		return
+	}
+
+	if *dict != "" {
+		if err := runBoggle(os.Stdout, *inputFile, *dict, *connect, *format); err != nil {
+			fmt.Printf("Error searching dictionary: %v\n", err)
+		}
+		return
+	}
+
+	if *render != "" {
+		if err := runRender(os.Stdout, *inputFile, *render, *colorBy, *pngFile); err != nil {
+			fmt.Printf("Error rendering grid: %v\n", err)
+		}
+		return
+	}
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(data, width, height))
-	fmt.Println("Part 2:", solvePart2(data, width, height))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// Cell is one cell of a template: a wildcard, or the set of runes it
+// accepts.
+type Cell []rune
+
+func (c Cell) String() string {
+	switch len(c) {
+	case 0:
+		return "."
+	case 1:
+		return string(c)
+	}
+	return "[" + string(c) + "]"
+}
+
+// Template is a rectangular 2D pattern. In its text form "." matches any
+// rune, "[MS]" matches M or S, and any other rune matches itself.
+type Template [][]Cell
+
+func parseTemplate(text string) (Template, error) {
+	var t Template
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		var row []Cell
+		runes := []rune(line)
+		for i := 0; i < len(runes); i++ {
+			switch runes[i] {
+			case '.':
+				row = append(row, nil)
+			case '[':
+				end := i + 1
+				for end < len(runes) && runes[end] != ']' {
+					end++
+				}
+				if end == len(runes) {
+					return nil, fmt.Errorf("unterminated class in template line %q", line)
+				}
+				if end == i+1 {
+					return nil, fmt.Errorf("empty class in template line %q", line)
+				}
+				row, i = append(row, Cell(runes[i+1:end])), end
+			default:
+				row = append(row, Cell{runes[i]})
+			}
+		}
+		if len(t) > 0 && len(row) != len(t[0]) {
+			return nil, fmt.Errorf("template is not rectangular: expected %d cells, found %d", len(t[0]), len(row))
+		}
+		t = append(t, row)
+	}
+	if len(t) == 0 || len(t[0]) == 0 {
+		return nil, fmt.Errorf("empty template")
+	}
+	return t, nil
+}
+
+func readTemplate(filename string) (Template, error) {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseTemplate(string(text))
+}
+
+func (t Template) String() string {
+	var sb strings.Builder
+	for _, row := range t {
+		for _, cell := range row {
+			sb.WriteString(cell.String())
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// key identifies t unambiguously, unlike String, where the class "[.]"
+// reads as a wildcard: every cell is written with its length first.
+func (t Template) key() string {
+	var sb strings.Builder
+	for _, row := range t {
+		for _, cell := range row {
+			fmt.Fprintf(&sb, "%d:%s", len(cell), string(cell))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// rotate returns t turned a quarter clockwise.
+func (t Template) rotate() Template {
+	h, w := len(t), len(t[0])
+	rotated := make(Template, w)
+	for r := range rotated {
+		rotated[r] = make([]Cell, h)
+		for c := range rotated[r] {
+			rotated[r][c] = t[h-1-c][r]
+		}
+	}
+	return rotated
+}
+
+// mirror returns t flipped left to right.
+func (t Template) mirror() Template {
+	mirrored := make(Template, len(t))
+	for r, row := range t {
+		mirrored[r] = make([]Cell, len(row))
+		for c, cell := range row {
+			mirrored[r][len(row)-1-c] = cell
+		}
+	}
+	return mirrored
+}
+
+// Orientation is a template turned and possibly mirrored, named after the
+// transformation, such as "rot90" or "mirror+rot180".
+type Orientation struct {
+	Name     string
+	Template Template
+}
+
+// Orientations returns the distinct rotations and reflections of t, so a
+// symmetric template yields fewer than eight.
+func (t Template) Orientations() []Orientation {
+	var orientations []Orientation
+	seen := make(map[string]bool)
+	for _, prefix := range [...]string{"", "mirror+"} {
+		turned := t
+		if prefix != "" {
+			turned = t.mirror()
+		}
+		for quarter := 0; quarter < 4; quarter++ {
+			if key := turned.key(); !seen[key] {
+				seen[key] = true
+				orientations = append(orientations, Orientation{fmt.Sprintf("%srot%d", prefix, 90*quarter), turned})
+			}
+			turned = turned.rotate()
+		}
+	}
+	return orientations
+}
+
+// bitRow is a set of columns of one grid row.
+type bitRow []uint64
+
+// andShifted keeps the columns c of dst such that c+shift is in src.
+func (dst bitRow) andShifted(src bitRow, shift int) {
+	words, offset := shift/64, uint(shift%64)
+	for i := range dst {
+		var v uint64
+		if j := i + words; j < len(src) {
+			v = src[j] >> offset
+			if offset > 0 && j+1 < len(src) {
+				v |= src[j+1] << (64 - offset)
+			}
+		}
+		dst[i] &= v
+	}
+}
+
+// Matcher finds templates in a grid bit-parallel: it indexes the columns of
+// every rune per row, so matching a template cell against a whole row is a
+// few word-wide ANDs instead of a comparison per grid cell.
+type Matcher struct {
+	grid    Grid
+	words   int
+	columns map[rune][]bitRow
+	classes map[string][]bitRow
+}
+
+func NewMatcher(grid Grid) *Matcher {
+	m := &Matcher{
+		grid:    grid,
+		words:   (grid.width + 63) / 64,
+		columns: make(map[rune][]bitRow),
+		classes: make(map[string][]bitRow),
+	}
+	for r, row := range grid.data {
+		for c, ch := range row {
+			rows, ok := m.columns[ch]
+			if !ok {
+				rows = make([]bitRow, grid.height)
+				for i := range rows {
+					rows[i] = make(bitRow, m.words)
+				}
+				m.columns[ch] = rows
+			}
+			rows[r][c/64] |= 1 << (c % 64)
+		}
+	}
+	return m
+}
+
+// cellRows returns the columns per row where the grid matches cell.
+func (m *Matcher) cellRows(cell Cell) []bitRow {
+	if len(cell) == 1 {
+		return m.columns[cell[0]]
+	}
+	key := string(cell)
+	if rows, ok := m.classes[key]; ok {
+		return rows
+	}
+	rows := make([]bitRow, m.grid.height)
+	for r := range rows {
+		rows[r] = make(bitRow, m.words)
+		for _, ch := range cell {
+			if chRows := m.columns[ch]; chRows != nil {
+				for i, v := range chRows[r] {
+					rows[r][i] |= v
+				}
+			}
+		}
+	}
+	m.classes[key] = rows
+	return rows
+}
+
+// PatternMatch is the top-left cell of a template match in one orientation.
+type PatternMatch struct {
+	Row         int    `json:"row"`
+	Col         int    `json:"col"`
+	Orientation string `json:"orientation"`
+}
+
+// Find returns the matches of every orientation in turn, each in reading
+// order.
+func (m *Matcher) Find(orientations []Orientation) []PatternMatch {
+	var matches []PatternMatch
+	for _, o := range orientations {
+		t := o.Template
+		h, w := len(t), len(t[0])
+		for r := 0; r+h <= m.grid.height; r++ {
+			candidates := make(bitRow, m.words)
+			for c := 0; c+w <= m.grid.width; c++ {
+				candidates[c/64] |= 1 << (c % 64)
+			}
+			for i, row := range t {
+				for j, cell := range row {
+					if cell == nil {
+						continue
+					}
+					if rows := m.cellRows(cell); rows != nil {
+						candidates.andShifted(rows[r+i], j)
+					} else {
+						clear(candidates)
+					}
+				}
+			}
+			for i, word := range candidates {
+				for ; word != 0; word &= word - 1 {
+					matches = append(matches, PatternMatch{r, i*64 + bits.TrailingZeros64(word), o.Name})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+func writePatternMatches(w io.Writer, matches []PatternMatch, orientations []Orientation, format string) error {
+	counts := make(map[string]int, len(orientations))
+	for _, o := range orientations {
+		counts[o.Name] = 0
+	}
+	for _, m := range matches {
+		counts[m.Orientation]++
+	}
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Matches []PatternMatch `json:"matches"`
+			Counts  map[string]int `json:"counts"`
+		}{matches, counts})
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, m := range matches {
+			fmt.Fprintf(tw, "%d,%d\t%s\n", m.Row, m.Col, m.Orientation)
+		}
+		fmt.Fprintln(tw)
+		for _, o := range orientations {
+			fmt.Fprintf(tw, "%s\t%d\n", o.Name, counts[o.Name])
+		}
+		fmt.Fprintf(tw, "total\t%d\n", len(matches))
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runPattern matches the template of templateFile against the grid of
+// filename, in every distinct orientation unless fixed is set.
+func runPattern(w io.Writer, filename, templateFile string, fixed bool, format string) error {
+	t, err := readTemplate(templateFile)
+	if err != nil {
+		return err
+	}
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	orientations := []Orientation{{"rot0", t}}
+	if !fixed {
+		orientations = t.Orientations()
+	}
+	matches := NewMatcher(Grid{data: data, width: width, height: height}).Find(orientations)
+	return writePatternMatches(w, matches, orientations, format)
+}
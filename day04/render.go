@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"unicode"
+)
+
+// Highlight is the cells of one match, with the key it is coloured by when
+// colouring per direction.
+type Highlight struct {
+	Cells [][2]int
+	Key   string
+}
+
+func wordHighlights(g Grid, matches []Match) []Highlight {
+	highlights := make([]Highlight, len(matches))
+	for i, m := range matches {
+		highlights[i] = Highlight{m.Cells(g), m.Dir.String()}
+	}
+	return highlights
+}
+
+// patternHighlights keeps the cells of each match that are not wildcards.
+func patternHighlights(matches []PatternMatch, orientations []Orientation) []Highlight {
+	templates := make(map[string]Template, len(orientations))
+	for _, o := range orientations {
+		templates[o.Name] = o.Template
+	}
+	highlights := make([]Highlight, len(matches))
+	for i, m := range matches {
+		var cells [][2]int
+		for r, row := range templates[m.Orientation] {
+			for c, cell := range row {
+				if cell != nil {
+					cells = append(cells, [2]int{m.Row + r, m.Col + c})
+				}
+			}
+		}
+		highlights[i] = Highlight{cells, m.Orientation}
+	}
+	return highlights
+}
+
+// ansiColors and pngColors are the same palette, in the order colours are
+// handed out.
+var (
+	ansiColors = [...]string{"31", "32", "33", "34", "35", "36", "91", "92", "93", "94", "95", "96"}
+	pngColors  = [...]color.RGBA{
+		{205, 49, 49, 255}, {13, 188, 121, 255}, {229, 229, 16, 255}, {36, 114, 200, 255},
+		{188, 63, 188, 255}, {17, 168, 205, 255}, {241, 76, 76, 255}, {35, 209, 139, 255},
+		{245, 245, 67, 255}, {59, 142, 234, 255}, {214, 112, 214, 255}, {41, 184, 219, 255},
+	}
+)
+
+// cellColors returns the palette index of every cell, or -1 for cells of no
+// match. A cell shared by several matches takes the colour of the last one.
+// by is none for a single colour, match for a colour per match, or
+// direction for a colour per direction or template orientation.
+func cellColors(g Grid, highlights []Highlight, by string) ([][]int, error) {
+	if by != "none" && by != "match" && by != "direction" {
+		return nil, fmt.Errorf("unknown colouring %q (expected none, match or direction)", by)
+	}
+	colors := make([][]int, g.height)
+	for r := range colors {
+		colors[r] = make([]int, g.width)
+		for c := range colors[r] {
+			colors[r][c] = -1
+		}
+	}
+	keys := make(map[string]int)
+	for i, h := range highlights {
+		var index int
+		switch by {
+		case "none":
+		case "match":
+			index = i % len(ansiColors)
+		case "direction":
+			if _, ok := keys[h.Key]; !ok {
+				keys[h.Key] = len(keys) % len(ansiColors)
+			}
+			index = keys[h.Key]
+		}
+		for _, cell := range h.Cells {
+			colors[cell[0]][cell[1]] = index
+		}
+	}
+	return colors, nil
+}
+
+// renderText prints the grid with the cells of no match replaced by '.',
+// colouring the others when ansi is set.
+func renderText(w io.Writer, g Grid, colors [][]int, ansi bool) {
+	for r, row := range g.data {
+		for c, ch := range row {
+			switch {
+			case colors[r][c] < 0:
+				fmt.Fprint(w, ".")
+			case ansi:
+				fmt.Fprintf(w, "\033[1;%sm%c\033[0m", ansiColors[colors[r][c]], ch)
+			default:
+				fmt.Fprintf(w, "%c", ch)
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// glyphs is a 3x5 pixel font for the letters of the grid.
+var glyphs = map[rune][5]string{
+	'A': {".#.", "#.#", "###", "#.#", "#.#"}, 'B': {"##.", "#.#", "##.", "#.#", "##."},
+	'C': {".##", "#..", "#..", "#..", ".##"}, 'D': {"##.", "#.#", "#.#", "#.#", "##."},
+	'E': {"###", "#..", "##.", "#..", "###"}, 'F': {"###", "#..", "##.", "#..", "#.."},
+	'G': {".##", "#..", "#.#", "#.#", ".##"}, 'H': {"#.#", "#.#", "###", "#.#", "#.#"},
+	'I': {"###", ".#.", ".#.", ".#.", "###"}, 'J': {"..#", "..#", "..#", "#.#", ".#."},
+	'K': {"#.#", "#.#", "##.", "#.#", "#.#"}, 'L': {"#..", "#..", "#..", "#..", "###"},
+	'M': {"#.#", "###", "###", "#.#", "#.#"}, 'N': {"##.", "#.#", "#.#", "#.#", "#.#"},
+	'O': {".#.", "#.#", "#.#", "#.#", ".#."}, 'P': {"##.", "#.#", "##.", "#..", "#.."},
+	'Q': {".#.", "#.#", "#.#", "##.", ".##"}, 'R': {"##.", "#.#", "##.", "#.#", "#.#"},
+	'S': {".##", "#..", ".#.", "..#", "##."}, 'T': {"###", ".#.", ".#.", ".#.", ".#."},
+	'U': {"#.#", "#.#", "#.#", "#.#", "###"}, 'V': {"#.#", "#.#", "#.#", "#.#", ".#."},
+	'W': {"#.#", "#.#", "###", "###", "#.#"}, 'X': {"#.#", "#.#", ".#.", "#.#", "#.#"},
+	'Y': {"#.#", "#.#", ".#.", ".#.", ".#."}, 'Z': {"###", "..#", ".#.", "#..", "###"},
+}
+
+const (
+	glyphScale = 2
+	cellWidth  = 3*glyphScale + 2
+	cellHeight = 5*glyphScale + 2
+)
+
+// writePNG draws every cell as a tile in the colour of its match, or dark
+// for cells of no match, with the letter on top.
+func writePNG(w io.Writer, g Grid, colors [][]int) error {
+	img := image.NewRGBA(image.Rect(0, 0, g.width*cellWidth, g.height*cellHeight))
+	background := color.RGBA{30, 30, 30, 255}
+	for r, row := range g.data {
+		for c, ch := range row {
+			tile, ink := background, color.RGBA{90, 90, 90, 255}
+			if colors[r][c] >= 0 {
+				tile, ink = pngColors[colors[r][c]], color.RGBA{0, 0, 0, 255}
+			}
+			x0, y0 := c*cellWidth, r*cellHeight
+			for y := 0; y < cellHeight; y++ {
+				for x := 0; x < cellWidth; x++ {
+					img.SetRGBA(x0+x, y0+y, tile)
+				}
+			}
+			glyph, ok := glyphs[unicode.ToUpper(ch)]
+			if !ok {
+				continue
+			}
+			for gy, line := range glyph {
+				for gx, px := range line {
+					if px != '#' {
+						continue
+					}
+					for dy := 0; dy < glyphScale; dy++ {
+						for dx := 0; dx < glyphScale; dx++ {
+							img.SetRGBA(x0+1+gx*glyphScale+dx, y0+1+gy*glyphScale+dy, ink)
+						}
+					}
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// runRender highlights the XMAS words (what "xmas") or the X-shaped MAS
+// patterns (what "x-mas") of filename, printing the grid as text or writing
+// it to pngFile when that is set.
+func runRender(w io.Writer, filename, what, by, pngFile string) error {
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	grid := Grid{data: data, width: width, height: height}
+
+	var highlights []Highlight
+	switch what {
+	case "xmas":
+		matches, _ := grid.Search([]string{"XMAS"}, SearchOptions{Directions: AllDirections, Overlap: true})
+		highlights = wordHighlights(grid, matches)
+	case "x-mas":
+		t, err := parseTemplate(xmasTemplate)
+		if err != nil {
+			return err
+		}
+		orientations := t.Orientations()
+		highlights = patternHighlights(NewMatcher(grid).Find(orientations), orientations)
+	default:
+		return fmt.Errorf("unknown rendering %q (expected xmas or x-mas)", what)
+	}
+	colors, err := cellColors(grid, highlights, by)
+	if err != nil {
+		return err
+	}
+
+	if pngFile == "" {
+		renderText(w, grid, colors, by != "none")
+		return nil
+	}
+	file, err := os.Create(pngFile)
+	if err != nil {
+		return err
+	}
+	if err := writePNG(file, grid, colors); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+var AllDirections = []Direction{NORTH, NORTHEAST, EAST, SOUTHEAST, SOUTH, SOUTHWEST, WEST, NORTHWEST}
+
+var directionNames = [...]string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+
+func (d Direction) String() string {
+	return directionNames[d]
+}
+
+func (d Direction) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// parseDirections reads a comma-separated list of directions such as
+// "E,SE,S", or one of the sets all, orthogonal and diagonal.
+func parseDirections(list string) ([]Direction, error) {
+	switch list {
+	case "all":
+		return AllDirections, nil
+	case "orthogonal":
+		return []Direction{NORTH, EAST, SOUTH, WEST}, nil
+	case "diagonal":
+		return []Direction{NORTHEAST, SOUTHEAST, SOUTHWEST, NORTHWEST}, nil
+	}
+	var dirs []Direction
+next:
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		for d, dirName := range directionNames {
+			if name == dirName {
+				dirs = append(dirs, Direction(d))
+				continue next
+			}
+		}
+		return nil, fmt.Errorf("unknown direction %q (expected all, orthogonal, diagonal or a list of %s)",
+			name, strings.Join(directionNames[:], ", "))
+	}
+	return dirs, nil
+}
+
+// SearchOptions restricts a word search. Wrap lets words run off one edge
+// of the grid and continue from the opposite one. Unless Overlap is set, a
+// cell belongs to at most one match, the first found in reading order.
+type SearchOptions struct {
+	Directions []Direction
+	Wrap       bool
+	Overlap    bool
+}
+
+// Match is a word found in the grid, starting at Row, Col.
+type Match struct {
+	Word string    `json:"word"`
+	Row  int       `json:"row"`
+	Col  int       `json:"col"`
+	Dir  Direction `json:"direction"`
+}
+
+// Cells returns the positions of every letter of the match.
+func (m Match) Cells(g Grid) [][2]int {
+	cells := make([][2]int, 0, len(m.Word))
+	r, c := m.Row, m.Col
+	for range []rune(m.Word) {
+		cells = append(cells, [2]int{r, c})
+		r, c = g.wrap(m.Dir.Move(r, c))
+	}
+	return cells
+}
+
+func (g Grid) wrap(r, c int) (int, int) {
+	return (r%g.height + g.height) % g.height, (c%g.width + g.width) % g.width
+}
+
+// spells reports whether word can be read from r, c in direction dir.
+func (g Grid) spells(word []rune, r, c int, dir Direction, wrap bool) bool {
+	for i, ch := range word {
+		if i > 0 {
+			r, c = dir.Move(r, c)
+			if wrap {
+				r, c = g.wrap(r, c)
+			}
+		}
+		if !g.IsValidPos(r, c) || g.data[r][c] != ch {
+			return false
+		}
+	}
+	return true
+}
+
+// Search finds every word of words in the grid and returns the matches in
+// reading order of their first letter, with the number of matches per word.
+func (g Grid) Search(words []string, opts SearchOptions) ([]Match, map[string]int) {
+	byFirst := make(map[rune][][]rune)
+	for _, word := range words {
+		if runes := []rune(word); len(runes) > 0 {
+			byFirst[runes[0]] = append(byFirst[runes[0]], runes)
+		}
+	}
+	dirs := opts.Directions
+	if dirs == nil {
+		dirs = AllDirections
+	}
+
+	var matches []Match
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word] = 0
+	}
+	used := make(map[[2]int]bool)
+	for r := 0; r < g.height; r++ {
+		for c := 0; c < g.width; c++ {
+			for _, word := range byFirst[g.data[r][c]] {
+				for _, dir := range dirs {
+					if !g.spells(word, r, c, dir, opts.Wrap) {
+						continue
+					}
+					match := Match{string(word), r, c, dir}
+					if !opts.Overlap {
+						cells := match.Cells(g)
+						if g.anyUsed(used, cells) {
+							continue
+						}
+						for _, cell := range cells {
+							used[cell] = true
+						}
+					}
+					matches = append(matches, match)
+					counts[match.Word]++
+				}
+			}
+		}
+	}
+	return matches, counts
+}
+
+func (g Grid) anyUsed(used map[[2]int]bool, cells [][2]int) bool {
+	for _, cell := range cells {
+		if used[cell] {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMatches(w io.Writer, matches []Match, counts map[string]int, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			Matches []Match        `json:"matches"`
+			Counts  map[string]int `json:"counts"`
+		}{matches, counts})
+	case "text":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, m := range matches {
+			fmt.Fprintf(tw, "%s\t%d,%d\t%s\n", m.Word, m.Row, m.Col, m.Dir)
+		}
+		fmt.Fprintln(tw)
+		words := make([]string, 0, len(counts))
+		for word := range counts {
+			words = append(words, word)
+		}
+		sort.Strings(words)
+		for _, word := range words {
+			fmt.Fprintf(tw, "%s\t%d\n", word, counts[word])
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runSearch searches the grid of filename for the comma-separated words.
+func runSearch(w io.Writer, filename, wordList, dirList string, opts SearchOptions, format string) error {
+	dirs, err := parseDirections(dirList)
+	if err != nil {
+		return err
+	}
+	opts.Directions = dirs
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	var words []string
+	for _, word := range strings.Split(wordList, ",") {
+		if word = strings.TrimSpace(word); word != "" {
+			words = append(words, word)
+		}
+	}
+	grid := Grid{data: data, width: width, height: height}
+	matches, counts := grid.Search(words, opts)
+	return writeMatches(w, matches, counts, format)
+}
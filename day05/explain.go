@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// RuleViolation is a rule whose pages appear in the wrong order, at
+// BeforeIndex and AfterIndex of the update.
+type RuleViolation struct {
+	Before      int `json:"before"`
+	After       int `json:"after"`
+	BeforeIndex int `json:"before_index"`
+	AfterIndex  int `json:"after_index"`
+}
+
+// Explanation tells why an update is out of order and how to fix it. Moves
+// is the number of pages to take out and put back elsewhere to reach
+// Corrected. It is the minimum when the rules allow a single order, which
+// Unique reports, and an upper bound otherwise.
+type Explanation struct {
+	Update     int             `json:"update"`
+	Pages      []int           `json:"pages"`
+	Valid      bool            `json:"valid"`
+	Violations []RuleViolation `json:"violations,omitempty"`
+	Moves      int             `json:"moves"`
+	Corrected  []int           `json:"corrected,omitempty"`
+	Unique     bool            `json:"unique"`
+	Error      string          `json:"error,omitempty"`
+}
+
+func violations(rules [][]int, sequence []int) []RuleViolation {
+	pos := make(map[int]int, len(sequence))
+	for i, v := range sequence {
+		pos[v] = i
+	}
+	var found []RuleViolation
+	for _, rule := range rules {
+		pBefore, okBefore := pos[rule[0]]
+		pAfter, okAfter := pos[rule[1]]
+		if okBefore && okAfter && pBefore >= pAfter {
+			found = append(found, RuleViolation{rule[0], rule[1], pBefore, pAfter})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].AfterIndex != found[j].AfterIndex {
+			return found[i].AfterIndex < found[j].AfterIndex
+		}
+		return found[i].BeforeIndex < found[j].BeforeIndex
+	})
+	return found
+}
+
+// movesTo returns how many pages of sequence must be moved to turn it into
+// order: the pages outside a longest subsequence already in that order.
+func movesTo(sequence, order []int) int {
+	rank := make(map[int]int, len(order))
+	for i, page := range order {
+		rank[page] = i
+	}
+	// Longest increasing subsequence of the ranks, by patience sorting
+	var tails []int
+	for _, page := range sequence {
+		r := rank[page]
+		i := sort.SearchInts(tails, r)
+		if i == len(tails) {
+			tails = append(tails, r)
+		} else {
+			tails[i] = r
+		}
+	}
+	return len(sequence) - len(tails)
+}
+
+func explainUpdates(rules, pages [][]int) []Explanation {
+	graph := ruleGraph(rules)
+	explanations := make([]Explanation, len(pages))
+	for i, page := range pages {
+		e := Explanation{Update: i, Pages: page, Violations: violations(rules, page), Unique: true}
+		e.Valid = len(e.Violations) == 0
+		if !e.Valid {
+			order, unique, err := topoOrder(graph, page)
+			if err != nil {
+				e.Error = err.Error()
+			} else {
+				e.Corrected, e.Unique, e.Moves = order, unique, movesTo(page, order)
+			}
+		}
+		explanations[i] = e
+	}
+	return explanations
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func writeExplanations(w io.Writer, explanations []Explanation, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanations)
+	case "text":
+		for _, e := range explanations {
+			if e.Valid {
+				fmt.Fprintf(w, "update %d %v: valid\n", e.Update, e.Pages)
+				continue
+			}
+			fmt.Fprintf(w, "update %d %v: %s violated\n", e.Update, e.Pages, plural(len(e.Violations), "rule"))
+			for _, v := range e.Violations {
+				fmt.Fprintf(w, "  %d|%d: %d at %d, %d at %d\n", v.Before, v.After, v.Before, v.BeforeIndex, v.After, v.AfterIndex)
+			}
+			switch {
+			case e.Error != "":
+				fmt.Fprintf(w, "  cannot be fixed: %s\n", e.Error)
+			case e.Unique:
+				fmt.Fprintf(w, "  corrected in %s: %v\n", plural(e.Moves, "move"), e.Corrected)
+			default:
+				fmt.Fprintf(w, "  corrected in at most %s (other orders are possible): %v\n", plural(e.Moves, "move"), e.Corrected)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runExplain prints the explanation of every update of filename.
+func runExplain(w io.Writer, filename, format string) error {
+	rules, pages, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	return writeExplanations(w, explainUpdates(rules, pages), format)
+}
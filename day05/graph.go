@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Edge is a rule of the graph. Redundant edges join two strongly connected
+// components that another path already joins, and are dropped by the
+// transitive reduction.
+type Edge struct {
+	From, To  int
+	Redundant bool
+}
+
+// RuleGraph is the directed graph of the rules, with pages as nodes, split
+// into strongly connected components. Rules inside a component form cycles,
+// so the transitive reduction is only taken between components and keeps
+// every edge inside them.
+type RuleGraph struct {
+	Nodes      []int
+	Edges      []Edge
+	Components [][]int
+	component  map[int]int
+}
+
+// buildRuleGraph makes the graph of rules, restricted to the pages of only
+// when it is not nil.
+func buildRuleGraph(rules [][]int, only []int) *RuleGraph {
+	keep := func(int) bool { return true }
+	if only != nil {
+		set := make(map[int]bool, len(only))
+		for _, page := range only {
+			set[page] = true
+		}
+		keep = func(page int) bool { return set[page] }
+	}
+
+	g := &RuleGraph{component: make(map[int]int)}
+	seen := make(map[int]bool)
+	for _, rule := range rules {
+		if !keep(rule[0]) || !keep(rule[1]) {
+			continue
+		}
+		g.Edges = append(g.Edges, Edge{From: rule[0], To: rule[1]})
+		for _, page := range rule {
+			if !seen[page] {
+				seen[page] = true
+				g.Nodes = append(g.Nodes, page)
+			}
+		}
+	}
+	for _, page := range only {
+		if !seen[page] {
+			seen[page] = true
+			g.Nodes = append(g.Nodes, page)
+		}
+	}
+	sort.Ints(g.Nodes)
+	g.findComponents()
+	g.markRedundant()
+	return g
+}
+
+// findComponents runs Tarjan's algorithm, which lists the components in
+// reverse topological order.
+func (g *RuleGraph) findComponents() {
+	succ := make(map[int][]int)
+	for _, e := range g.Edges {
+		succ[e.From] = append(succ[e.From], e.To)
+	}
+	index := make(map[int]int)
+	low := make(map[int]int)
+	onStack := make(map[int]bool)
+	var stack []int
+
+	var visit func(v int)
+	visit = func(v int) {
+		index[v], low[v] = len(index), len(index)
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range succ[v] {
+			if _, ok := index[w]; !ok {
+				visit(w)
+				low[v] = min(low[v], low[w])
+			} else if onStack[w] {
+				low[v] = min(low[v], index[w])
+			}
+		}
+		if low[v] == index[v] {
+			var comp []int
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				g.component[w] = len(g.Components)
+				comp = append(comp, w)
+				if w == v {
+					break
+				}
+			}
+			sort.Ints(comp)
+			g.Components = append(g.Components, comp)
+		}
+	}
+	for _, v := range g.Nodes {
+		if _, ok := index[v]; !ok {
+			visit(v)
+		}
+	}
+}
+
+// markRedundant flags the edges between components u and v when v can also
+// be reached from u through another component.
+func (g *RuleGraph) markRedundant() {
+	n := len(g.Components)
+	next := make([]map[int]bool, n)
+	for i := range next {
+		next[i] = make(map[int]bool)
+	}
+	for _, e := range g.Edges {
+		if u, v := g.component[e.From], g.component[e.To]; u != v {
+			next[u][v] = true
+		}
+	}
+	// Components come in reverse topological order, so successors first
+	reach := make([]map[int]bool, n)
+	for u := 0; u < n; u++ {
+		reach[u] = make(map[int]bool)
+		for v := range next[u] {
+			reach[u][v] = true
+			for w := range reach[v] {
+				reach[u][w] = true
+			}
+		}
+	}
+	for i, e := range g.Edges {
+		u, v := g.component[e.From], g.component[e.To]
+		if u == v {
+			continue
+		}
+		for w := range next[u] {
+			if w != v && reach[w][v] {
+				g.Edges[i].Redundant = true
+				break
+			}
+		}
+	}
+}
+
+// cyclic returns the components of more than one page, largest first.
+func (g *RuleGraph) cyclic() [][]int {
+	var comps [][]int
+	for _, comp := range g.Components {
+		if len(comp) > 1 {
+			comps = append(comps, comp)
+		}
+	}
+	sort.SliceStable(comps, func(i, j int) bool { return len(comps[i]) > len(comps[j]) })
+	return comps
+}
+
+func (g *RuleGraph) writeStats(w io.Writer) {
+	redundant := 0
+	for _, e := range g.Edges {
+		if e.Redundant {
+			redundant++
+		}
+	}
+	var sizes []string
+	for _, comp := range g.cyclic() {
+		sizes = append(sizes, fmt.Sprint(len(comp)))
+	}
+	if sizes == nil {
+		sizes = []string{"none"}
+	}
+	fmt.Fprintln(w, "Pages:", len(g.Nodes))
+	fmt.Fprintln(w, "Rules:", len(g.Edges))
+	fmt.Fprintln(w, "Components:", len(g.Components))
+	fmt.Fprintln(w, "Cyclic component sizes:", strings.Join(sizes, ", "))
+	fmt.Fprintln(w, "Redundant rules:", redundant)
+}
+
+// sccColors fill the cyclic components, cycling when there are more.
+var sccColors = [...]string{"#fde2e2", "#e2f0fd", "#e2fde6", "#fdf6e2", "#efe2fd", "#e2fdfb"}
+
+func (g *RuleGraph) writeDOT(w io.Writer, reduce bool) {
+	fmt.Fprintln(w, "digraph rules {")
+	fmt.Fprintln(w, "  node [shape=circle];")
+	inCluster := make(map[int]bool)
+	for i, comp := range g.cyclic() {
+		fmt.Fprintf(w, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(w, "    label=\"SCC %d (%d pages)\"; style=filled; fillcolor=\"%s\";\n", i, len(comp), sccColors[i%len(sccColors)])
+		for _, page := range comp {
+			fmt.Fprintf(w, "    %d;\n", page)
+			inCluster[page] = true
+		}
+		fmt.Fprintln(w, "  }")
+	}
+	for _, page := range g.Nodes {
+		if !inCluster[page] {
+			fmt.Fprintf(w, "  %d;\n", page)
+		}
+	}
+	for _, e := range g.Edges {
+		switch {
+		case e.Redundant && reduce:
+		case e.Redundant:
+			fmt.Fprintf(w, "  %d -> %d [style=dashed];\n", e.From, e.To)
+		default:
+			fmt.Fprintf(w, "  %d -> %d;\n", e.From, e.To)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func (g *RuleGraph) writeMermaid(w io.Writer, reduce bool) {
+	fmt.Fprintln(w, "flowchart LR")
+	inCluster := make(map[int]bool)
+	for i, comp := range g.cyclic() {
+		fmt.Fprintf(w, "  subgraph scc%d [\"SCC %d (%d pages)\"]\n", i, i, len(comp))
+		for _, page := range comp {
+			fmt.Fprintf(w, "    p%d((%d))\n", page, page)
+			inCluster[page] = true
+		}
+		fmt.Fprintln(w, "  end")
+		fmt.Fprintf(w, "  style scc%d fill:%s\n", i, sccColors[i%len(sccColors)])
+	}
+	for _, page := range g.Nodes {
+		if !inCluster[page] {
+			fmt.Fprintf(w, "  p%d((%d))\n", page, page)
+		}
+	}
+	for _, e := range g.Edges {
+		switch {
+		case e.Redundant && reduce:
+		case e.Redundant:
+			fmt.Fprintf(w, "  p%d -.-> p%d\n", e.From, e.To)
+		default:
+			fmt.Fprintf(w, "  p%d --> p%d\n", e.From, e.To)
+		}
+	}
+}
+
+// runGraph exports the rules of filename, or only those among the pages of
+// one update when update is not negative, as DOT or Mermaid to outFile
+// ("" for stdout). Redundant rules are dashed, or dropped when reduce is
+// set. The summary goes to stdout, or to stderr when the graph does.
+func runGraph(filename, format string, update int, reduce bool, outFile string) error {
+	rules, pages, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	var only []int
+	if update >= 0 {
+		if update >= len(pages) {
+			return fmt.Errorf("update %d out of range (the input has %d)", update, len(pages))
+		}
+		only = pages[update]
+	}
+	g := buildRuleGraph(rules, only)
+
+	var write func(io.Writer, bool)
+	switch format {
+	case "dot":
+		write = g.writeDOT
+	case "mermaid":
+		write = g.writeMermaid
+	default:
+		return fmt.Errorf("unknown graph format %q (expected dot or mermaid)", format)
+	}
+
+	if outFile == "" {
+		write(os.Stdout, reduce)
+		g.writeStats(os.Stderr)
+		return nil
+	}
+	file, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	write(file, reduce)
+	if err := file.Close(); err != nil {
+		return err
+	}
+	g.writeStats(os.Stdout)
+	return nil
+}
@@ -3,10 +3,26 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+	explain   = flag.Bool("explain", false, "explain the rules every update violates and how to fix it")
+	format    = flag.String("format", "text", "output format for -explain: text or json")
+	graph     = flag.String("graph", "", "export the rule graph as dot or mermaid instead of solving")
+	update    = flag.Int("update", -1, "restrict -graph to the pages of the update with this index")
+	reduce    = flag.Bool("reduce", false, "drop the rules of -graph implied by other rules")
+	out       = flag.String("out", "", "write -graph to this `file` instead of stdout")
+	serve     = flag.Bool("serve", false, "load -input (none if empty) and apply rule and update commands read from stdin")
 )
 
 func parseFile(filename string) ([][]int, [][]int, error) {
@@ -77,85 +93,104 @@ func parseFile(filename string) ([][]int, [][]int, error) {
 	return rules, pages, nil
 }
 
-func validateOrder(rules [][]int, sequence []int) bool {
-	// Create position map
-	pos := make(map[int]int)
-	for i, v := range sequence {
-		pos[v] = i
-	}
-
-	// Check each rule
-	for _, rule := range rules {
-		before, after := rule[0], rule[1]
-		// If both elements exist in sequence
-		if pBefore, okBefore := pos[before]; okBefore {
-			if pAfter, okAfter := pos[after]; okAfter {
-				if pBefore >= pAfter {
-					return false
-				}
-			}
+func solvePart1(rules, pages [][]int) int {
+	store := NewRuleStore(rules)
+	for i, page := range pages {
+		_, valid := store.AddUpdate(page)
+		if trace.Enabled() {
+			trace.Step("update", i, "pages", len(page), "valid", valid)
 		}
 	}
-	return true
+	return store.MiddleSum()
 }
 
-func fixOrder(rules [][]int, sequence []int) []int {
-	// Create position map
-	pos := make(map[int]int)
-	for i, v := range sequence {
-		pos[v] = i
-	}
-
-	// Check each rule
-	for _, rule := range rules {
-		before, after := rule[0], rule[1]
-		// If both elements exist in sequence
-		if pBefore, okBefore := pos[before]; okBefore {
-			if pAfter, okAfter := pos[after]; okAfter {
-				if pBefore >= pAfter {
-					pos[before], pos[after] = pAfter, pBefore
-				}
+func solvePart2(rules, pages [][]int) (int, error) {
+	graph := ruleGraph(rules)
+	store := NewRuleStore(rules)
+	res := 0
+	for i, page := range pages {
+		if _, valid := store.AddUpdate(page); !valid {
+			order, unique, err := topoOrder(graph, page)
+			if err != nil {
+				return 0, fmt.Errorf("update %d %v: %w", i, page, err)
+			}
+			if !unique {
+				slog.Warn("rules allow several orders, the middle page may be ambiguous", "update", i, "order", order)
+			}
+			res += order[len(order)/2]
+			if trace.Enabled() {
+				trace.Step("update", i, "pages", len(order), "unique", unique, "acc", res)
 			}
 		}
 	}
-	res := make([]int, len(sequence))
-	for number, pos := range pos {
-		res[pos] = number
+	return res, nil
+}
+
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	rules, pages, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
 	}
-	return res
+	return func() any { return solvePart1(rules, pages) },
+		func() any {
+			res, err := solvePart2(rules, pages)
+			if err != nil {
+				return err
+			}
+			return res
+		}, nil
 }
 
-func solvePart1(rules, pages [][]int) int {
-	res := 0
-	for _, page := range pages {
-		if validateOrder(rules, page) {
-			res += page[len(page)/2]
+func main() {
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if *explain {
+		if err := runExplain(os.Stdout, *inputFile, *format); err != nil {
+			fmt.Printf("Error explaining updates: %v\n", err)
 		}
+		return
 	}
-	return res
-}
 
-func solvePart2(rules, pages [][]int) int {
-	res := 0
-	for _, page := range pages {
-		if !validateOrder(rules, page) {
-			count := 0
-			for !validateOrder(rules, page) && (count < 10) {
-				page = fixOrder(rules, page)
-				count++
-			}
-			res += page[len(page)/2]
+	if *graph != "" {
+		if err := runGraph(*inputFile, *graph, *update, *reduce, *out); err != nil {
+			fmt.Printf("Error exporting graph: %v\n", err)
 		}
+		return
 	}
-	return res
-}
 
-func main() {
-	rules, pages, err := parseFile("input.txt")
+	if *serve {
+		if err := runServe(*inputFile, os.Stdin, os.Stdout); err != nil {
+			fmt.Printf("Error serving commands: %v\n", err)
+		}
+		return
+	}
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
-	fmt.Println("Part 1:", solvePart1(rules, pages))
-	fmt.Println("Part 2:", solvePart2(rules, pages))
+
+	for i, part := range []func() any{part1, part2} {
+		answer := part()
+		if err, ok := answer.(error); ok {
+			fmt.Printf("Error solving part %d: %v\n", i+1, err)
+			return
+		}
+		fmt.Printf("Part %d: %v\n", i+1, answer)
+	}
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseRule reads a rule written as before|after.
+func parseRule(text string) (before, after int, err error) {
+	left, right, ok := strings.Cut(text, "|")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rule %q, expected two numbers separated by |", text)
+	}
+	if before, err = strconv.Atoi(strings.TrimSpace(left)); err != nil {
+		return 0, 0, err
+	}
+	if after, err = strconv.Atoi(strings.TrimSpace(right)); err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// parseUpdate reads an update written as comma-separated pages.
+func parseUpdate(text string) ([]int, error) {
+	var pages []int
+	for _, field := range strings.Split(text, ",") {
+		page, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+func printFlips(w io.Writer, flips []Flip) {
+	if len(flips) == 0 {
+		fmt.Fprintln(w, "ok, no update changed")
+		return
+	}
+	for _, flip := range flips {
+		fmt.Fprintf(w, "update %d: %s\n", flip.Update, validity(flip.Valid))
+	}
+}
+
+func validity(valid bool) string {
+	if valid {
+		return "valid"
+	}
+	return "invalid"
+}
+
+// runServe loads the rules and updates of filename, unless it is empty,
+// into a store and runs the commands of r on it.
+func runServe(filename string, r io.Reader, w io.Writer) error {
+	store := NewRuleStore(nil)
+	if filename != "" {
+		rules, pages, err := parseFile(filename)
+		if err != nil {
+			return err
+		}
+		store = NewRuleStore(rules)
+		for _, page := range pages {
+			store.AddUpdate(page)
+		}
+	}
+	return serveCommands(store, r, w)
+}
+
+// serveCommands runs the command protocol of the store, one command per line of r:
+//
+//	add rule 47|53       remove rule 47|53
+//	add update 75,47,61  remove update 3
+//	show 3               list
+//	sum                  (the part 1 answer of the stored updates)
+//
+// Rule changes print the updates that flipped between valid and invalid.
+// Blank lines and lines starting with # are ignored.
+func serveCommands(store *RuleStore, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := command(store, w, strings.Fields(line)); err != nil {
+			fmt.Fprintln(w, "error:", err)
+		}
+	}
+	return scanner.Err()
+}
+
+func command(store *RuleStore, w io.Writer, fields []string) error {
+	arg := func(i int) string {
+		if i < len(fields) {
+			return strings.Join(fields[i:], "")
+		}
+		return ""
+	}
+	switch {
+	case len(fields) >= 3 && fields[1] == "rule" && (fields[0] == "add" || fields[0] == "remove"):
+		before, after, err := parseRule(arg(2))
+		if err != nil {
+			return err
+		}
+		if fields[0] == "add" {
+			printFlips(w, store.AddRule(before, after))
+		} else {
+			printFlips(w, store.RemoveRule(before, after))
+		}
+	case len(fields) >= 3 && fields[0] == "add" && fields[1] == "update":
+		pages, err := parseUpdate(arg(2))
+		if err != nil {
+			return err
+		}
+		id, valid := store.AddUpdate(pages)
+		fmt.Fprintf(w, "update %d: %s\n", id, validity(valid))
+	case len(fields) == 3 && fields[0] == "remove" && fields[1] == "update":
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return err
+		}
+		if !store.RemoveUpdate(id) {
+			return fmt.Errorf("no update %d", id)
+		}
+		fmt.Fprintf(w, "update %d removed\n", id)
+	case len(fields) == 2 && fields[0] == "show":
+		id, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return err
+		}
+		pages, valid, ok := store.Update(id)
+		if !ok {
+			return fmt.Errorf("no update %d", id)
+		}
+		fmt.Fprintf(w, "update %d %v: %s\n", id, pages, validity(valid))
+	case len(fields) == 1 && fields[0] == "list":
+		for _, id := range store.IDs() {
+			pages, valid, _ := store.Update(id)
+			fmt.Fprintf(w, "update %d %v: %s\n", id, pages, validity(valid))
+		}
+	case len(fields) == 1 && fields[0] == "sum":
+		fmt.Fprintln(w, store.MiddleSum())
+	default:
+		return fmt.Errorf("unknown command %q", strings.Join(fields, " "))
+	}
+	return nil
+}
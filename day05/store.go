@@ -0,0 +1,172 @@
+package main
+
+import "sort"
+
+// Flip is an update whose validity changed.
+type Flip struct {
+	Update int
+	Valid  bool
+}
+
+// RuleStore keeps rules and updates and the validity of every update up to
+// date as either changes. It indexes the updates by page and counts the
+// rules each update violates, so a change to a rule only touches the
+// updates holding both of its pages.
+type RuleStore struct {
+	after      map[int]map[int]bool // page to the pages required after it
+	updates    map[int][]int
+	byPage     map[int]map[int]bool // page to the updates holding it
+	violations map[int]int
+	nextID     int
+}
+
+// NewRuleStore returns a store holding rules and no updates.
+func NewRuleStore(rules [][]int) *RuleStore {
+	s := &RuleStore{
+		after:      make(map[int]map[int]bool),
+		updates:    make(map[int][]int),
+		byPage:     make(map[int]map[int]bool),
+		violations: make(map[int]int),
+	}
+	for _, rule := range rules {
+		s.AddRule(rule[0], rule[1])
+	}
+	return s
+}
+
+// HasRule reports whether before|after is a rule.
+func (s *RuleStore) HasRule(before, after int) bool {
+	return s.after[before][after]
+}
+
+// breaks counts the pairs of positions of pages where before|after is
+// broken, after coming first. A page may repeat, and a rule may name the
+// same page twice, so there can be several.
+func breaks(pages []int, before, after int) int {
+	count, seen := 0, 0
+	for _, page := range pages {
+		if page == before {
+			count += seen
+		}
+		if page == after {
+			seen++
+		}
+	}
+	return count
+}
+
+// affected returns the updates holding both pages, with the number of
+// times each breaks before|after.
+func (s *RuleStore) affected(before, after int) map[int]int {
+	found := make(map[int]int)
+	for id := range s.byPage[before] {
+		if s.byPage[after][id] {
+			found[id] = breaks(s.updates[id], before, after)
+		}
+	}
+	return found
+}
+
+// change applies a rule change to the updates that break it, adding delta
+// for every break, and returns those that flipped.
+func (s *RuleStore) change(before, after, delta int) []Flip {
+	var flips []Flip
+	for id, count := range s.affected(before, after) {
+		if count == 0 {
+			continue
+		}
+		wasValid := s.violations[id] == 0
+		s.violations[id] += delta * count
+		if valid := s.violations[id] == 0; valid != wasValid {
+			flips = append(flips, Flip{id, valid})
+		}
+	}
+	sort.Slice(flips, func(i, j int) bool { return flips[i].Update < flips[j].Update })
+	return flips
+}
+
+// AddRule adds before|after and returns the updates it made invalid.
+func (s *RuleStore) AddRule(before, after int) []Flip {
+	if s.HasRule(before, after) {
+		return nil
+	}
+	if s.after[before] == nil {
+		s.after[before] = make(map[int]bool)
+	}
+	s.after[before][after] = true
+	return s.change(before, after, 1)
+}
+
+// RemoveRule removes before|after and returns the updates it made valid.
+func (s *RuleStore) RemoveRule(before, after int) []Flip {
+	if !s.HasRule(before, after) {
+		return nil
+	}
+	delete(s.after[before], after)
+	return s.change(before, after, -1)
+}
+
+// AddUpdate stores an update and returns its id and validity.
+func (s *RuleStore) AddUpdate(pages []int) (id int, valid bool) {
+	id = s.nextID
+	s.nextID++
+	for _, page := range pages {
+		if s.byPage[page] == nil {
+			s.byPage[page] = make(map[int]bool)
+		}
+		s.byPage[page][id] = true
+	}
+	count := 0
+	for i, page := range pages {
+		for _, earlier := range pages[:i] {
+			if s.after[page][earlier] {
+				count++
+			}
+		}
+	}
+	s.updates[id] = pages
+	s.violations[id] = count
+	return id, count == 0
+}
+
+// RemoveUpdate forgets an update and reports whether it existed.
+func (s *RuleStore) RemoveUpdate(id int) bool {
+	pages, ok := s.updates[id]
+	if !ok {
+		return false
+	}
+	for _, page := range pages {
+		delete(s.byPage[page], id)
+	}
+	delete(s.updates, id)
+	delete(s.violations, id)
+	return true
+}
+
+// Update returns the pages of an update and whether it is valid.
+func (s *RuleStore) Update(id int) (pages []int, valid, ok bool) {
+	pages, ok = s.updates[id]
+	return pages, ok && s.violations[id] == 0, ok
+}
+
+// IDs returns the ids of the stored updates in increasing order.
+func (s *RuleStore) IDs() []int {
+	ids := make([]int, 0, len(s.updates))
+	for id := range s.updates {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// MiddleSum adds up the middle page of every valid update, the answer of
+// part 1.
+func (s *RuleStore) MiddleSum() int {
+	sum := 0
+	for id, pages := range s.updates {
+		if s.violations[id] == 0 {
+			sum += pages[len(pages)/2]
+		}
+	}
+	return sum
+}
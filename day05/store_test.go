@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestRuleStoreRepeatedPage(t *testing.T) {
+	store := NewRuleStore([][]int{{1, 2}})
+	// 2 comes before both copies of 1, so 1|2 is broken twice
+	id, valid := store.AddUpdate([]int{2, 1, 1})
+	if valid {
+		t.Fatalf("update [2 1 1] is valid under 1|2")
+	}
+	flips := store.RemoveRule(1, 2)
+	if len(flips) != 1 || flips[0] != (Flip{id, true}) {
+		t.Errorf("RemoveRule(1, 2) flips = %v; want [{%d true}]", flips, id)
+	}
+	if _, valid, _ := store.Update(id); !valid {
+		t.Errorf("update [2 1 1] is still invalid without rules")
+	}
+	flips = store.AddRule(1, 2)
+	if len(flips) != 1 || flips[0] != (Flip{id, false}) {
+		t.Errorf("AddRule(1, 2) flips = %v; want [{%d false}]", flips, id)
+	}
+
+	// A page between two copies of the other breaks the rule only once
+	id, _ = store.AddUpdate([]int{1, 2, 1})
+	store.RemoveRule(1, 2)
+	if _, valid, _ := store.Update(id); !valid {
+		t.Errorf("update [1 2 1] is still invalid without rules")
+	}
+}
+
+func TestRuleStoreSelfRule(t *testing.T) {
+	store := NewRuleStore(nil)
+	once, _ := store.AddUpdate([]int{3, 4, 5})
+	twice, _ := store.AddUpdate([]int{4, 3, 4})
+
+	flips := store.AddRule(4, 4)
+	if len(flips) != 1 || flips[0] != (Flip{twice, false}) {
+		t.Errorf("AddRule(4, 4) flips = %v; want [{%d false}]", flips, twice)
+	}
+	if _, valid, _ := store.Update(once); !valid {
+		t.Errorf("update [3 4 5] holds 4 once but breaks 4|4")
+	}
+	flips = store.RemoveRule(4, 4)
+	if len(flips) != 1 || flips[0] != (Flip{twice, true}) {
+		t.Errorf("RemoveRule(4, 4) flips = %v; want [{%d true}]", flips, twice)
+	}
+
+	// Added while the rule holds, the update must become valid once it goes
+	store.AddRule(4, 4)
+	id, valid := store.AddUpdate([]int{4, 4, 4})
+	if valid {
+		t.Fatalf("update [4 4 4] is valid under 4|4")
+	}
+	store.RemoveRule(4, 4)
+	if _, valid, _ := store.Update(id); !valid {
+		t.Errorf("update [4 4 4] is still invalid once 4|4 is removed")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ruleGraph maps every page to the pages the rules require after it.
+func ruleGraph(rules [][]int) map[int][]int {
+	graph := make(map[int][]int)
+	for _, rule := range rules {
+		graph[rule[0]] = append(graph[rule[0]], rule[1])
+	}
+	return graph
+}
+
+// CycleError reports rules that contradict each other on the pages of an
+// update: Pages lists a cycle, each page required before the next and the
+// last before the first.
+type CycleError struct {
+	Pages []int
+}
+
+func (e *CycleError) Error() string {
+	steps := make([]string, len(e.Pages)+1)
+	for i, page := range e.Pages {
+		steps[i] = fmt.Sprint(page)
+	}
+	steps[len(e.Pages)] = fmt.Sprint(e.Pages[0])
+	return "rules form a cycle: " + strings.Join(steps, " -> ")
+}
+
+// topoOrder sorts the pages of an update by the rules of graph restricted
+// to those pages, keeping the update's order between unrelated pages. It
+// reports whether the order is the only one the rules allow, and returns a
+// *CycleError when the rules contradict each other.
+func topoOrder(graph map[int][]int, pages []int) (order []int, unique bool, err error) {
+	index := make(map[int]int, len(pages))
+	for i, page := range pages {
+		index[page] = i
+	}
+	succ := make([][]int, len(pages))
+	inDegree := make([]int, len(pages))
+	for i, page := range pages {
+		for _, after := range graph[page] {
+			if j, ok := index[after]; ok && j != i {
+				succ[i] = append(succ[i], j)
+				inDegree[j]++
+			}
+		}
+	}
+
+	// Kahn's algorithm, taking the earliest ready page first
+	ready := make([]bool, len(pages))
+	for i := range pages {
+		ready[i] = inDegree[i] == 0
+	}
+	unique = true
+	order = make([]int, 0, len(pages))
+	for len(order) < len(pages) {
+		next, count := -1, 0
+		for i, ok := range ready {
+			if ok {
+				if next == -1 {
+					next = i
+				}
+				count++
+			}
+		}
+		if next == -1 {
+			return nil, false, &CycleError{findCycle(succ, inDegree, pages)}
+		}
+		unique = unique && count == 1
+		ready[next] = false
+		order = append(order, pages[next])
+		for _, j := range succ[next] {
+			if inDegree[j]--; inDegree[j] == 0 {
+				ready[j] = true
+			}
+		}
+	}
+	return order, unique, nil
+}
+
+// findCycle returns a cycle among the pages Kahn's algorithm could not
+// place. Each of them still has an unplaced predecessor, so walking from one
+// predecessor to the next must come back to a page already seen.
+func findCycle(succ [][]int, inDegree []int, pages []int) []int {
+	pred := make([][]int, len(pages))
+	for i, js := range succ {
+		for _, j := range js {
+			pred[j] = append(pred[j], i)
+		}
+	}
+	start := 0
+	for inDegree[start] == 0 {
+		start++
+	}
+
+	seen := make(map[int]int)
+	var walk []int
+	for i := start; ; {
+		if at, ok := seen[i]; ok {
+			// walk[k+1] comes before walk[k], so read the loop backwards
+			cycle := make([]int, 0, len(walk)-at)
+			for k := len(walk) - 1; k >= at; k-- {
+				cycle = append(cycle, pages[walk[k]])
+			}
+			return cycle
+		}
+		seen[i] = len(walk)
+		walk = append(walk, i)
+		for _, p := range pred[i] {
+			if inDegree[p] > 0 {
+				i = p
+				break
+			}
+		}
+	}
+}
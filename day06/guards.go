@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"aoc2024/internal/progress"
+	"aoc2024/internal/trace"
+)
+
+var errNoGuard = errors.New("no guard (^, >, v or <) on the map")
+
+// findGuards returns every guard of the map in reading order, facing the
+// way its glyph points.
+func findGuards(data [][]rune, width, height int) ([]GuardState, error) {
+	var guards []GuardState
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			for dir, glyph := range guardGlyphs {
+				if data[r][c] == rune(glyph) {
+					guards = append(guards, GuardState{Position{r, c}, dir})
+				}
+			}
+		}
+	}
+	if len(guards) == 0 {
+		return nil, errNoGuard
+	}
+	return guards, nil
+}
+
+// Collision is what happens when guards meet. With CollideNone they walk
+// through each other and patrol independently. With CollideBlock a guard
+// turns in front of another as if it were an obstacle. With CollideHalt
+// guards that step onto the same cell, or through each other, stop there.
+type Collision int
+
+const (
+	CollideNone Collision = iota
+	CollideBlock
+	CollideHalt
+)
+
+func parseCollision(name string) (Collision, error) {
+	switch name {
+	case "none":
+		return CollideNone, nil
+	case "block":
+		return CollideBlock, nil
+	case "halt":
+		return CollideHalt, nil
+	}
+	return CollideNone, fmt.Errorf("unknown collision rule %q (expected none, block or halt)", name)
+}
+
+// noBlock is an obstacle position off the map, for walks without one.
+var noBlock = Position{-2, -2}
+
+// patrol walks all guards together, one cell per tick in reading order,
+// with an extra obstacle at block, and returns the cells they visit and
+// whether they walk forever. Like solveSteps, a guard stops once it reaches
+// the border of the map.
+func patrol(data [][]rune, width, height int, guards []GuardState, rule Collision, block Position) (map[Position]struct{}, bool) {
+	guards = append([]GuardState(nil), guards...)
+	active := make([]bool, len(guards))
+	visited := make(map[Position]struct{})
+	for i, guard := range guards {
+		visited[guard.pos] = struct{}{}
+		active[i] = !(guard.pos.r <= 0 || guard.pos.r >= height-1 || guard.pos.c <= 0 || guard.pos.c >= width-1)
+	}
+	occupied := func(r, c, self int) bool {
+		for j, other := range guards {
+			if j != self && other.pos == (Position{r, c}) {
+				return true
+			}
+		}
+		return false
+	}
+	blocked := func(r, c, self int) bool {
+		return data[r][c] == '#' || (Position{r, c}) == block || rule == CollideBlock && occupied(r, c, self)
+	}
+
+	seen := make(map[string]struct{})
+	key := make([]byte, 0, len(guards)*4)
+	for {
+		key = key[:0]
+		moving := false
+		for i, guard := range guards {
+			moving = moving || active[i]
+			state := (guard.pos.r*width+guard.pos.c)*len(moves) + guard.dir
+			if active[i] {
+				state = -state - 1
+			}
+			key = binary.LittleEndian.AppendUint32(key, uint32(state))
+		}
+		if !moving {
+			return visited, false
+		}
+		if _, ok := seen[string(key)]; ok {
+			return visited, true
+		}
+		seen[string(key)] = struct{}{}
+
+		previous := append([]GuardState(nil), guards...)
+		for i := range guards {
+			if !active[i] {
+				continue
+			}
+			guard := &guards[i]
+			rNext, cNext := guard.peek()
+			for turns := 0; blocked(rNext, cNext, i) && turns < len(moves); turns++ {
+				guard.dir = (guard.dir + 1) % len(moves) // turn
+				rNext, cNext = guard.peek()
+			}
+			if !blocked(rNext, cNext, i) {
+				guard.pos = Position{rNext, cNext}
+				visited[guard.pos] = struct{}{}
+			}
+		}
+		for i, guard := range guards {
+			if !active[i] {
+				continue
+			}
+			if guard.pos.r <= 0 || guard.pos.r >= height-1 || guard.pos.c <= 0 || guard.pos.c >= width-1 {
+				active[i] = false
+			}
+			if rule != CollideHalt {
+				continue
+			}
+			for j, other := range guards {
+				swapped := guard.pos == previous[j].pos && other.pos == previous[i].pos
+				if j != i && (other.pos == guard.pos || swapped) {
+					active[i], active[j] = false, false
+				}
+			}
+		}
+	}
+}
+
+// checkLeave returns an error for the first guard that never reaches the
+// border of the map on its own, walking a loop or boxed in, since solveSteps
+// would then walk forever.
+func checkLeave(data [][]rune, width, height int, guards []GuardState) error {
+	table := newJumpTable(data, width, height)
+	visited := make([]uint64, (width*height*len(moves)+63)/64)
+	for i, guard := range guards {
+		if table.loops(guard, noBlock, visited) {
+			return fmt.Errorf("guard %d at %d,%d never leaves the map", i, guard.pos.r, guard.pos.c)
+		}
+	}
+	return nil
+}
+
+// solveGuards is part 1 for several guards: the cells any of them visits.
+// Guards that collide are walked together and may loop, but guards that
+// patrol independently must each leave the map.
+func solveGuards(data [][]rune, width, height int, guards []GuardState, rule Collision) (map[Position]struct{}, error) {
+	if rule != CollideNone {
+		visited, _ := patrol(data, width, height, guards, rule, noBlock)
+		return visited, nil
+	}
+	if err := checkLeave(data, width, height, guards); err != nil {
+		return nil, err
+	}
+	visited := make(map[Position]struct{})
+	for i, guard := range guards {
+		name := "day06-walk"
+		if i > 0 {
+			name = fmt.Sprintf("day06-walk-%d", i)
+		}
+		_, steps := solveSteps(data, width, height, guard, noBlock, name)
+		for pos := range steps {
+			visited[pos] = struct{}{}
+		}
+	}
+	return visited, nil
+}
+
+// countGuardLoops is part 2 for guards that collide: it walks them all
+// together for every candidate obstacle, so it is much slower than
+// countLoops.
+func countGuardLoops(data [][]rune, width, height int, guards []GuardState, rule Collision, candidates []Position) int {
+	bar := progress.New("obstacles", len(candidates))
+	defer bar.Finish()
+	bad := 0
+	for i, pos := range candidates {
+		_, loop := patrol(data, width, height, guards, rule, pos)
+		if loop {
+			bad++
+		}
+		if trace.Enabled() {
+			trace.Step("obstacle", i, "r", pos.r, "c", pos.c, "loop", loop, "loops", bad)
+		}
+		bar.Add(1)
+	}
+	return bad
+}
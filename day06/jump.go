@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"aoc2024/internal/intmath"
+	"aoc2024/internal/progress"
+	"aoc2024/internal/trace"
+)
+
+// exit marks a jump that reaches the border of the map, where the walk
+// stops.
+const exit = -1
+
+// jumpTable holds, for every cell and direction, the cell where the guard
+// stops in front of the next obstacle, or exit. The walk then goes from
+// turn to turn instead of cell by cell.
+type jumpTable struct {
+	width, height int
+	next          [4][]int
+}
+
+func newJumpTable(data [][]rune, width, height int) *jumpTable {
+	t := &jumpTable{width: width, height: height}
+	for dir, move := range moves {
+		next := make([]int, width*height)
+		// Visit the cells so that the neighbour ahead is always done first
+		rows, cols := span(height, move.r), span(width, move.c)
+		for _, r := range rows {
+			for _, c := range cols {
+				nr, nc := r+move.r, c+move.c
+				switch {
+				case nr < 0 || nr >= height || nc < 0 || nc >= width:
+					next[r*width+c] = exit
+				case data[nr][nc] == '#':
+					next[r*width+c] = r*width + c
+				default:
+					next[r*width+c] = next[nr*width+nc]
+				}
+			}
+		}
+		t.next[dir] = next
+	}
+	return t
+}
+
+// span lists 0..n-1, backwards when moving forwards along the axis.
+func span(n, step int) []int {
+	s := make([]int, n)
+	for i := range s {
+		if step > 0 {
+			s[i] = n - 1 - i
+		} else {
+			s[i] = i
+		}
+	}
+	return s
+}
+
+// jump returns where the guard stops from cell pos facing dir, with the
+// one extra obstacle at block patched over the table.
+func (t *jumpTable) jump(pos, dir int, block Position) int {
+	stop := t.next[dir][pos]
+	move := moves[dir]
+	r, c := pos/t.width, pos%t.width
+	// Steps from pos to block, if block is straight ahead
+	k := -1
+	switch {
+	case move.r == 0 && block.r == r && (block.c-c)*move.c > 0:
+		k = (block.c - c) * move.c
+	case move.c == 0 && block.c == c && (block.r-r)*move.r > 0:
+		k = (block.r - r) * move.r
+	}
+	if k < 0 {
+		return stop
+	}
+	if stop != exit {
+		if ks := max(intmath.Abs(stop/t.width-r), intmath.Abs(stop%t.width-c)); ks < k {
+			return stop
+		}
+	}
+	return (block.r-move.r)*t.width + block.c - move.c
+}
+
+func (t *jumpTable) onBorder(pos Position) bool {
+	return pos.r <= 0 || pos.r >= t.height-1 || pos.c <= 0 || pos.c >= t.width-1
+}
+
+// loops reports whether the guard walks forever once an obstacle is put at
+// block. visited is scratch space of one bit per cell and direction.
+func (t *jumpTable) loops(guard GuardState, block Position, visited []uint64) bool {
+	if t.onBorder(guard.pos) {
+		return false
+	}
+	clear(visited)
+	pos, dir := guard.pos.r*t.width+guard.pos.c, guard.dir
+	for {
+		if pos = t.jump(pos, dir, block); pos == exit {
+			return false
+		}
+		state := pos*len(moves) + dir
+		if visited[state/64]&(1<<(state%64)) != 0 {
+			return true
+		}
+		visited[state/64] |= 1 << (state % 64)
+		dir = (dir + 1) % len(moves) // turn
+	}
+}
+
+// countLoops tries an obstacle on every candidate cell in parallel and
+// counts those that trap any of the guards, patrolling independently, in a
+// loop. The workers share the jump table read-only and each has its own
+// visited bitset; the added obstacle is only patched into the jumps, so the
+// map itself is never written.
+func countLoops(data [][]rune, width, height int, guards []GuardState, candidates []Position) int {
+	table := newJumpTable(data, width, height)
+	bar := progress.New("obstacles", len(candidates))
+	defer bar.Finish()
+
+	var bad atomic.Int64
+	var next atomic.Int64
+	var failure atomic.Value
+	var wg sync.WaitGroup
+	for range runtime.GOMAXPROCS(0) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Hand a panic over to the caller, where it can be recovered
+			defer func() {
+				if r := recover(); r != nil {
+					failure.CompareAndSwap(nil, fmt.Sprintf("%v\n%s", r, debug.Stack()))
+					next.Store(int64(len(candidates))) // stop the other workers
+				}
+			}()
+			visited := make([]uint64, (width*height*len(moves)+63)/64)
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= len(candidates) {
+					return
+				}
+				pos := candidates[i]
+				loop := false
+				for _, guard := range guards {
+					if loop = table.loops(guard, pos, visited); loop {
+						break
+					}
+				}
+				if loop {
+					bad.Add(1)
+				}
+				if trace.Enabled() {
+					trace.Step("obstacle", i, "r", pos.r, "c", pos.c, "loop", loop)
+				}
+				bar.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if r := failure.Load(); r != nil {
+		panic(r)
+	}
+	return int(bad.Load())
+}
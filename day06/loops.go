@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var directionNames = [...]string{"up", "right", "down", "left"}
+
+// Turn is a turn of the guard in a loop. Turning twice in a row, in a dead
+// end, shows up as two turns on the same cell.
+type Turn struct {
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Loop is the cycle a guard ends up in once an obstruction is added: it
+// enters the cycle after Entry steps and then repeats the same Length steps
+// over Cells forever. A guard boxed in on all four sides cycles without
+// stepping: Length is 0 and the four Turns are all on its one cell.
+type Loop struct {
+	Row    int      `json:"row"`
+	Col    int      `json:"col"`
+	Guard  int      `json:"guard"`
+	Entry  int      `json:"entry_step"`
+	Length int      `json:"length"`
+	Cells  [][2]int `json:"cells"`
+	Turns  []Turn   `json:"turns"`
+
+	moves []GuardState
+}
+
+// traceLoop walks the guard cell by cell with an extra obstacle at block,
+// like solveSteps, and returns the cycle it ends up in, or false when it
+// reaches the border. Being boxed in counts as a cycle, as in countLoops.
+func traceLoop(data [][]rune, width, height int, guard GuardState, block Position) (Loop, bool) {
+	blocked := func(r, c int) bool { return data[r][c] == '#' || (Position{r, c}) == block }
+	seen := map[GuardState]int{guard: 0}
+	states := []GuardState{guard}
+	var turns []Turn
+	var turnStep []int // step of each turn
+
+	for step := 1; guard.pos.r > 0 && guard.pos.r < height-1 && guard.pos.c > 0 && guard.pos.c < width-1; step++ {
+		rNext, cNext := guard.peek()
+		for turn := 0; blocked(rNext, cNext); turn++ {
+			if turn == len(moves) {
+				// Boxed in: the guard turns round on this cell forever
+				loop := Loop{Row: block.r, Col: block.c, Entry: step - 1, moves: []GuardState{guard}}
+				loop.Cells = [][2]int{{guard.pos.r, guard.pos.c}}
+				loop.Turns = turns[len(turns)-len(moves):]
+				return loop, true
+			}
+			from := guard.dir
+			guard.dir = (guard.dir + 1) % len(moves) // turn
+			turns = append(turns, Turn{guard.pos.r, guard.pos.c, directionNames[from], directionNames[guard.dir]})
+			turnStep = append(turnStep, step-1)
+			rNext, cNext = guard.peek()
+		}
+		guard.pos.r, guard.pos.c = rNext, cNext // Step forward
+		if entry, ok := seen[guard]; ok {
+			loop := Loop{Row: block.r, Col: block.c, Entry: entry, Length: step - entry, moves: states[entry:]}
+			inCycle := make(map[Position]bool)
+			for _, state := range loop.moves {
+				if !inCycle[state.pos] {
+					inCycle[state.pos] = true
+					loop.Cells = append(loop.Cells, [2]int{state.pos.r, state.pos.c})
+				}
+			}
+			for i, turn := range turns {
+				if turnStep[i] >= entry {
+					loop.Turns = append(loop.Turns, turn)
+				}
+			}
+			return loop, true
+		}
+		seen[guard] = step
+		states = append(states, guard)
+	}
+	return Loop{}, false
+}
+
+// render draws the loop over the map in the style of the puzzle: | and -
+// for cells crossed vertically or horizontally, + for turns and crossings,
+// and O for the added obstruction.
+func (l Loop) render(data [][]rune, guards []GuardState) []string {
+	const vertical, horizontal = 1, 2
+	marks := make(map[Position]int)
+	for i, state := range l.moves {
+		next := l.moves[(i+1)%len(l.moves)]
+		axis := horizontal
+		if moves[next.dir].r != 0 {
+			axis = vertical
+		}
+		marks[state.pos] |= axis
+		marks[next.pos] |= axis
+	}
+	for _, turn := range l.Turns {
+		marks[Position{turn.Row, turn.Col}] = vertical | horizontal
+	}
+
+	lines := make([]string, len(data))
+	for r, row := range data {
+		line := []rune(string(row))
+		for c := range line {
+			switch marks[Position{r, c}] {
+			case vertical:
+				line[c] = '|'
+			case horizontal:
+				line[c] = '-'
+			case vertical | horizontal:
+				line[c] = '+'
+			}
+		}
+		for _, guard := range guards {
+			if guard.pos.r == r {
+				line[guard.pos.c] = rune(guardGlyphs[guard.dir])
+			}
+		}
+		if l.Row == r {
+			line[l.Col] = 'O'
+		}
+		lines[r] = string(line)
+	}
+	return lines
+}
+
+// findLoops explains every obstruction that traps a guard in a loop, in
+// reading order, using the jump table to skip the obstructions that do not.
+func findLoops(data [][]rune, width, height int, guards []GuardState) ([]Loop, error) {
+	steps, err := solveGuards(data, width, height, guards, CollideNone)
+	if err != nil {
+		return nil, err
+	}
+	for _, guard := range guards {
+		delete(steps, guard.pos)
+	}
+	candidates := make([]Position, 0, len(steps))
+	for pos := range steps {
+		candidates = append(candidates, pos)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].r != candidates[j].r {
+			return candidates[i].r < candidates[j].r
+		}
+		return candidates[i].c < candidates[j].c
+	})
+
+	table := newJumpTable(data, width, height)
+	visited := make([]uint64, (width*height*len(moves)+63)/64)
+	var loops []Loop
+	for _, pos := range candidates {
+		for i, guard := range guards {
+			if !table.loops(guard, pos, visited) {
+				continue
+			}
+			if loop, ok := traceLoop(data, width, height, guard, pos); ok {
+				loop.Guard = i
+				loops = append(loops, loop)
+				break
+			}
+		}
+	}
+	return loops, nil
+}
+
+func writeLoops(w io.Writer, data [][]rune, guards []GuardState, loops []Loop, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(loops)
+	case "text":
+		for _, l := range loops {
+			fmt.Fprintf(w, "obstruction at %d,%d: guard %d enters a loop at step %d, %d steps over %d cells with %d turns\n",
+				l.Row, l.Col, l.Guard, l.Entry, l.Length, len(l.Cells), len(l.Turns))
+			for _, turn := range l.Turns {
+				fmt.Fprintf(w, "  turn at %d,%d from %s to %s\n", turn.Row, turn.Col, turn.From, turn.To)
+			}
+			fmt.Fprintln(w, strings.Join(l.render(data, guards), "\n"))
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "Loop-causing obstructions: %d\n", len(loops))
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (expected text or json)", format)
+}
+
+// runLoops explains the loops of the map of filename.
+func runLoops(w io.Writer, filename, format string) error {
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	guards, err := findGuards(data, width, height)
+	if err != nil {
+		return err
+	}
+	loops, err := findLoops(data, width, height, guards)
+	if err != nil {
+		return err
+	}
+	return writeLoops(w, data, guards, loops, format)
+}
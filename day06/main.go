@@ -3,8 +3,23 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/record"
+	"aoc2024/internal/trace"
+)
+
+var (
+	inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+	collide   = flag.String("collide", "none", "what guards do when they meet: none, block or halt")
+	loops     = flag.Bool("loops", false, "explain the loop caused by every obstruction of part 2")
+	format    = flag.String("format", "text", "output format for -loops and -optimize: text or json")
+	optimize  = flag.String("optimize", "", "search obstructions for the first guard instead of solving: walk or trap")
+	region    = flag.String("region", "", "rectangle `top,left,bottom,right` to trap the guard in with -optimize trap")
+	maxBlocks = flag.Int("max-obstructions", 3, "most obstructions -optimize trap may place")
 )
 
 func parseFile(filename string) ([][]rune, int, int, error) {
@@ -55,89 +70,165 @@ func (guard GuardState) peek() (r, c int) {
 	return guard.pos.r + moves[guard.dir].r, guard.pos.c + moves[guard.dir].c
 }
 
-func findGuard(data [][]rune, width, height int) GuardState {
-	for r := 0; r < height; r++ {
-		for c := 0; c < width; c++ {
-			if data[r][c] == '^' {
-				return GuardState{Position{r, c}, 0}
-			}
-		}
+var guardGlyphs = []byte{'^', '>', 'v', '<'}
+
+// walkFrame renders the map with visited cells marked X, for recordings.
+type walkFrame struct {
+	frame []byte
+	width int
+}
+
+func newWalkFrame(data [][]rune, width int) *walkFrame {
+	var frame []byte
+	for _, row := range data {
+		frame = append(frame, string(row)...)
+		frame = append(frame, '\n')
 	}
-	return GuardState{}
+	return &walkFrame{frame, width}
+}
+
+func (f *walkFrame) move(from Position, guard GuardState) []byte {
+	f.frame[from.r*(f.width+1)+from.c] = 'X'
+	f.frame[guard.pos.r*(f.width+1)+guard.pos.c] = guardGlyphs[guard.dir]
+	return f.frame
 }
 
-func solveSteps(data [][]rune, width, height int, guard GuardState) (int, map[Position]struct{}) {
+// solveSteps walks the guard until it reaches the border, with an extra
+// obstacle at block, and returns the cells it visits. The walk is recorded
+// under name, unless name is empty. The walk must not loop, but a guard
+// boxed in on all four sides just stays where it is.
+func solveSteps(data [][]rune, width, height int, guard GuardState, block Position, name string) (int, map[Position]struct{}) {
 	steps := make(map[Position]struct{})
 	steps[guard.pos] = struct{}{}
 
-	for guard.pos.r > 0 && guard.pos.r < height-1 && guard.pos.c > 0 && guard.pos.c < width-1 {
+	var rec *record.Recorder
+	if name != "" {
+		rec = record.New(name)
+		defer rec.Close()
+	}
+	var frame *walkFrame
+	if rec != nil {
+		frame = newWalkFrame(data, width)
+		rec.Snapshot(0, frame.move(guard.pos, guard))
+	}
+
+	for step := 1; guard.pos.r > 0 && guard.pos.r < height-1 && guard.pos.c > 0 && guard.pos.c < width-1; step++ {
 		rNext, cNext := guard.peek() // peek
-		if data[rNext][cNext] == '#' {
+		for turn := 0; data[rNext][cNext] == '#' || (Position{rNext, cNext}) == block; turn++ {
+			if turn == len(moves) {
+				return len(steps), steps // boxed in, never moving again
+			}
 			guard.dir = (guard.dir + 1) % len(moves) // turn
 			rNext, cNext = guard.peek()              // peek
 		}
+		from := guard.pos
 		guard.pos.r, guard.pos.c = rNext, cNext // Step forward
+		if rec != nil {
+			rec.Snapshot(step, frame.move(from, guard))
+		}
 		_, ok := steps[guard.pos]
 		if !ok {
 			steps[guard.pos] = struct{}{}
 		}
+		if trace.Enabled() {
+			trace.Step("walk", step, "r", guard.pos.r, "c", guard.pos.c, "dir", guard.dir, "visited", len(steps))
+		}
 	}
 	return len(steps), steps
 }
 
-func solvePart1(data [][]rune, width, height int) int {
-	guard := findGuard(data, width, height)
-	count, _ := solveSteps(data, width, height, guard)
-	return count
+func solvePart1(data [][]rune, width, height int, guards []GuardState, rule Collision) (int, error) {
+	visited, err := solveGuards(data, width, height, guards, rule)
+	return len(visited), err
 }
 
-func isLoop(data [][]rune, width, height int, guard GuardState) bool {
-	visited := make(map[GuardState]struct{})
-	visited[guard] = struct{}{}
+func solvePart2(data [][]rune, width, height int, guards []GuardState, rule Collision) (int, error) {
+	steps, err := solveGuards(data, width, height, guards, rule)
+	if err != nil {
+		return 0, err
+	}
+	for _, guard := range guards {
+		delete(steps, guard.pos)
+	}
 
-	for guard.pos.r > 0 && guard.pos.r < height-1 && guard.pos.c > 0 && guard.pos.c < width-1 {
-		rNext, cNext := guard.peek() // peek
-		for data[rNext][cNext] == '#' {
-			guard.dir = (guard.dir + 1) % len(moves) // turn
-			rNext, cNext = guard.peek()              // peek
+	candidates := make([]Position, 0, len(steps))
+	for pos := range steps {
+		candidates = append(candidates, pos)
+	}
+	if rule != CollideNone && len(guards) > 1 {
+		return countGuardLoops(data, width, height, guards, rule, candidates), nil
+	}
+	return countLoops(data, width, height, guards, candidates), nil
+}
 
-		}
-		guard.pos.r, guard.pos.c = rNext, cNext // Step forward
-		_, ok := visited[guard]
-		if !ok {
-			visited[guard] = struct{}{}
-		} else {
-			return true
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	rule, err := parseCollision(*collide)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	guards, err := findGuards(data, width, height)
+	if err != nil {
+		return nil, nil, err
+	}
+	part := func(solve func([][]rune, int, int, []GuardState, Collision) (int, error)) func() any {
+		return func() any {
+			count, err := solve(data, width, height, guards, rule)
+			if err != nil {
+				return err
+			}
+			return count
 		}
 	}
-	return false
+	return part(solvePart1), part(solvePart2), nil
 }
 
-func solvePart2(data [][]rune, width, height int) int {
-	guard := findGuard(data, width, height)
-	_, steps := solveSteps(data, width, height, guard)
+func main() {
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
 
-	bad := 0
-	for pos := range steps {
-		if pos == guard.pos {
-			continue
+	if *loops {
+		if err := runLoops(os.Stdout, *inputFile, *format); err != nil {
+			fmt.Printf("Error explaining loops: %v\n", err)
 		}
-		data[pos.r][pos.c] = '#'
-		loop := isLoop(data, width, height, guard)
-		data[pos.r][pos.c] = '.'
-		if loop {
-			bad++
+		return
+	}
+
+	if *optimize != "" {
+		if err := runOptimize(os.Stdout, *inputFile, *optimize, *region, *maxBlocks, *format); err != nil {
+			fmt.Printf("Error optimizing obstructions: %v\n", err)
 		}
+		return
 	}
-	return bad
-}
 
-func main() {
-	data, width, height, err := parseFile("input.txt")
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
-	fmt.Println("Part 1:", solvePart1(data, width, height))
-	fmt.Println("Part 2:", solvePart2(data, width, height))
+
+	for i, part := range []func() any{part1, part2} {
+		answer := part()
+		if err, ok := answer.(error); ok {
+			fmt.Printf("Error solving part %d: %v\n", i+1, err)
+			return
+		}
+		fmt.Printf("Part %d: %v\n", i+1, answer)
+	}
 }
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"aoc2024/internal/progress"
+)
+
+// Placement is a choice of obstructions and the number of distinct cells
+// the guard then visits.
+type Placement struct {
+	Obstructions [][2]int `json:"obstructions"`
+	Visited      int      `json:"visited"`
+}
+
+// Optimum is the result of the single obstruction search: the walk without
+// any, and the obstructions that make the guard visit the most and the
+// fewest cells before it leaves. Obstructions that trap the guard in a loop
+// are left out, since it never leaves.
+type Optimum struct {
+	Baseline int        `json:"baseline"`
+	Longest  *Placement `json:"longest"`
+	Shortest *Placement `json:"shortest"`
+}
+
+// optimizeObstruction tries an obstruction on every cell of the guard's
+// path, the only ones that change it, and keeps the best of each kind.
+func optimizeObstruction(data [][]rune, width, height int, guard GuardState) Optimum {
+	baseline, steps := solveSteps(data, width, height, guard, noBlock, "")
+	opt := Optimum{Baseline: baseline}
+	table := newJumpTable(data, width, height)
+	visited := make([]uint64, (width*height*len(moves)+63)/64)
+
+	bar := progress.New("obstructions", len(steps)-1) // all but the guard's cell
+	defer bar.Finish()
+	for r := 0; r < height; r++ {
+		for c := 0; c < width; c++ {
+			pos := Position{r, c}
+			if _, ok := steps[pos]; !ok || pos == guard.pos {
+				continue
+			}
+			bar.Add(1)
+			if table.loops(guard, pos, visited) {
+				continue
+			}
+			count, _ := solveSteps(data, width, height, guard, pos, "")
+			placement := &Placement{[][2]int{{r, c}}, count}
+			if opt.Longest == nil || count > opt.Longest.Visited {
+				opt.Longest = placement
+			}
+			if opt.Shortest == nil || count < opt.Shortest.Visited {
+				opt.Shortest = placement
+			}
+		}
+	}
+	return opt
+}
+
+// Region is a rectangle of the map, corners included.
+type Region struct {
+	Top, Left, Bottom, Right int
+}
+
+func parseRegion(text string) (Region, error) {
+	fields := strings.Split(text, ",")
+	if len(fields) != 4 {
+		return Region{}, fmt.Errorf("invalid region %q, expected top,left,bottom,right", text)
+	}
+	var n [4]int
+	for i, field := range fields {
+		var err error
+		if n[i], err = strconv.Atoi(strings.TrimSpace(field)); err != nil {
+			return Region{}, fmt.Errorf("invalid region %q: %v", text, err)
+		}
+	}
+	return Region{n[0], n[1], n[2], n[3]}, nil
+}
+
+func (reg Region) contains(pos Position) bool {
+	return pos.r >= reg.Top && pos.r <= reg.Bottom && pos.c >= reg.Left && pos.c <= reg.Right
+}
+
+// escape walks the guard like solveSteps with extra obstacles at blocks.
+// It returns the cells visited and whether the guard gets out of reg or
+// reaches the border of the map; the last cell is then where it got out.
+func escape(data [][]rune, width, height int, guard GuardState, blocks map[Position]bool, reg Region) ([]Position, bool) {
+	blocked := func(r, c int) bool { return data[r][c] == '#' || blocks[Position{r, c}] }
+	seen := map[GuardState]bool{guard: true}
+	path := []Position{guard.pos}
+	for {
+		if !reg.contains(guard.pos) {
+			return path, true
+		}
+		if guard.pos.r <= 0 || guard.pos.r >= height-1 || guard.pos.c <= 0 || guard.pos.c >= width-1 {
+			return path, true
+		}
+		rNext, cNext := guard.peek()
+		for turn := 0; blocked(rNext, cNext); turn++ {
+			if turn == len(moves) {
+				return path, false // boxed in
+			}
+			guard.dir = (guard.dir + 1) % len(moves) // turn
+			rNext, cNext = guard.peek()
+		}
+		guard.pos.r, guard.pos.c = rNext, cNext // Step forward
+		if seen[guard] {
+			return path, false
+		}
+		seen[guard] = true
+		path = append(path, guard.pos)
+	}
+}
+
+// trapGuard finds a smallest set of at most limit obstructions that keeps
+// the guard inside reg forever. It deepens the search one obstruction at a
+// time; at each level only the cells of the path up to the escape can
+// change the walk, so those are the only ones tried.
+func trapGuard(data [][]rune, width, height int, guard GuardState, reg Region, limit int) (*Placement, error) {
+	if !reg.contains(guard.pos) {
+		return nil, fmt.Errorf("the guard at %d,%d starts outside the region", guard.pos.r, guard.pos.c)
+	}
+	blocks := make(map[Position]bool)
+	var search func(depth int) bool
+	search = func(depth int) bool {
+		path, escaped := escape(data, width, height, guard, blocks, reg)
+		if !escaped {
+			return true
+		}
+		if depth == 0 {
+			return false
+		}
+		tried := make(map[Position]bool)
+		for _, pos := range path[1:] {
+			if tried[pos] || pos == guard.pos {
+				continue
+			}
+			tried[pos] = true
+			blocks[pos] = true
+			if search(depth - 1) {
+				return true
+			}
+			delete(blocks, pos)
+		}
+		return false
+	}
+
+	for depth := 0; depth <= limit; depth++ {
+		if !search(depth) {
+			continue
+		}
+		path, _ := escape(data, width, height, guard, blocks, reg)
+		cells := make(map[Position]bool)
+		for _, pos := range path {
+			cells[pos] = true
+		}
+		placement := &Placement{Obstructions: [][2]int{}, Visited: len(cells)}
+		for r := 0; r < height; r++ {
+			for c := 0; c < width; c++ {
+				if blocks[Position{r, c}] {
+					placement.Obstructions = append(placement.Obstructions, [2]int{r, c})
+				}
+			}
+		}
+		return placement, nil
+	}
+	return nil, nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (p *Placement) String() string {
+	if p == nil {
+		return "none"
+	}
+	cells := make([]string, len(p.Obstructions))
+	for i, cell := range p.Obstructions {
+		cells[i] = fmt.Sprintf("%d,%d", cell[0], cell[1])
+	}
+	return fmt.Sprintf("obstructions at %s, %d cells visited", strings.Join(cells, " "), p.Visited)
+}
+
+// runOptimize searches obstructions for the first guard of the map of
+// filename: the single one that makes its walk longest and shortest
+// (goal "walk"), or the fewest that trap it in region (goal "trap").
+func runOptimize(w io.Writer, filename, goal, region string, limit int, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %q (expected text or json)", format)
+	}
+	data, width, height, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	guards, err := findGuards(data, width, height)
+	if err != nil {
+		return err
+	}
+
+	switch goal {
+	case "walk":
+		if err := checkLeave(data, width, height, guards[:1]); err != nil {
+			return err
+		}
+		opt := optimizeObstruction(data, width, height, guards[0])
+		if format == "json" {
+			return writeJSON(w, opt)
+		}
+		fmt.Fprintln(w, "Without obstruction:", opt.Baseline, "cells visited")
+		fmt.Fprintln(w, "Longest walk:", opt.Longest)
+		fmt.Fprintln(w, "Shortest walk:", opt.Shortest)
+		return nil
+	case "trap":
+		reg, err := parseRegion(region)
+		if err != nil {
+			return err
+		}
+		placement, err := trapGuard(data, width, height, guards[0], reg, limit)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			return writeJSON(w, placement)
+		}
+		if placement == nil {
+			fmt.Fprintf(w, "No set of at most %d obstructions traps the guard\n", limit)
+			return nil
+		}
+		fmt.Fprintln(w, "Trap:", placement)
+		return nil
+	}
+	return fmt.Errorf("unknown goal %q (expected walk or trap)", goal)
+}
@@ -3,12 +3,19 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/intmath"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) ([]int, [][]int, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -48,6 +55,18 @@ func parseFile(filename string) ([]int, [][]int, error) {
 	return allGoals, allValues, nil
 }
 
+// appendChecked appends acc+value and acc*value to next, leaving out those
+// that overflow and could otherwise wrap around onto the goal.
+func appendChecked(next []int, acc, value int) []int {
+	if sum, ok := intmath.CheckedAdd(acc, value); ok {
+		next = append(next, sum)
+	}
+	if prod, ok := intmath.CheckedMul(acc, value); ok {
+		next = append(next, prod)
+	}
+	return next
+}
+
 func solvePart1(allGoals []int, allValues [][]int) int {
 	total := 0
 	for i, values := range allValues {
@@ -55,7 +74,7 @@ func solvePart1(allGoals []int, allValues [][]int) int {
 		for _, value := range values[1:] {
 			var next []int
 			for _, acc := range curr {
-				next = append(next, acc+value, acc*value)
+				next = appendChecked(next, acc, value)
 			}
 			curr = next
 		}
@@ -65,23 +84,13 @@ func solvePart1(allGoals []int, allValues [][]int) int {
 				break
 			}
 		}
+		if trace.Enabled() {
+			trace.Step("equation", i, "goal", allGoals[i], "candidates", len(curr), "total", total)
+		}
 	}
 	return total
 }
 
-func concat(a, b int) int {
-	digits := 0
-	temp := b
-	for temp > 0 {
-		digits++
-		temp /= 10
-	}
-	for i := 0; i < digits; i++ {
-		a *= 10
-	}
-	return a + b
-}
-
 func solvePart2(allGoals []int, allValues [][]int) int {
 	total := 0
 	for i, values := range allValues {
@@ -89,7 +98,10 @@ func solvePart2(allGoals []int, allValues [][]int) int {
 		for _, value := range values[1:] {
 			var next []int
 			for _, acc := range curr {
-				next = append(next, acc+value, acc*value, concat(acc, value))
+				next = appendChecked(next, acc, value)
+				if joined, ok := intmath.Concat(acc, value); ok {
+					next = append(next, joined)
+				}
 			}
 			curr = next
 		}
@@ -99,17 +111,45 @@ func solvePart2(allGoals []int, allValues [][]int) int {
 				break
 			}
 		}
+		if trace.Enabled() {
+			trace.Step("equation", i, "goal", allGoals[i], "candidates", len(curr), "total", total)
+		}
 	}
 	return total
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	allGoals, allValues, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(allGoals, allValues) },
+		func() any { return solvePart2(allGoals, allValues) }, nil
+}
+
 func main() {
-	allGoals, allValues, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(allGoals, allValues))
-	fmt.Println("Part 2:", solvePart2(allGoals, allValues))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
@@ -3,10 +3,16 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "test.txt", "puzzle input `file`")
+
 func parseFile(filename string) ([][]rune, int, int, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -37,13 +43,6 @@ func parseFile(filename string) ([][]rune, int, int, error) {
 	return grid, height, width, nil
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
 type Position struct{ r, c int }
 
 func (p Position) isInside(height, width int) bool {
@@ -67,7 +66,8 @@ func solvePart1(data [][]rune, height, width int) int {
 		}
 	}
 	antinodes := make(map[Position]struct{})
-	for _, antennas := range antennaMap {
+	step := 0
+	for freq, antennas := range antennaMap {
 		for i := 0; i < len(antennas)-1; i++ {
 			for j := i + 1; j < len(antennas); j++ {
 				an0 := nthAntinode(antennas[i], antennas[j], 1)
@@ -80,6 +80,10 @@ func solvePart1(data [][]rune, height, width int) int {
 				}
 			}
 		}
+		if trace.Enabled() {
+			trace.Step("frequency", step, "freq", string(freq), "antennas", len(antennas), "antinodes", len(antinodes))
+		}
+		step++
 	}
 	return len(antinodes)
 }
@@ -97,7 +101,8 @@ func solvePart2(data [][]rune, height, width int) int {
 		}
 	}
 	antinodes := make(map[Position]struct{})
-	for _, antennas := range antennaMap {
+	step := 0
+	for freq, antennas := range antennaMap {
 		for i := 0; i < len(antennas)-1; i++ {
 			for j := i + 1; j < len(antennas); j++ {
 				curr := make(map[Position]struct{})
@@ -120,17 +125,46 @@ func solvePart2(data [][]rune, height, width int) int {
 				}
 			}
 		}
+		if trace.Enabled() {
+			trace.Step("frequency", step, "freq", string(freq), "antennas", len(antennas), "antinodes", len(antinodes))
+		}
+		step++
 	}
 	return len(antinodes)
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, height, width, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data, height, width) },
+		func() any { return solvePart2(data, height, width) }, nil
+}
+
 func main() {
-	data, height, width, err := parseFile("test.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(data, height, width))
-	fmt.Println("Part 2:", solvePart2(data, height, width))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
@@ -3,10 +3,16 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) ([]int, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -85,6 +91,9 @@ func solvePart2(data []int) int {
 				fs[pSpace].size -= fs[pLast].size
 				fs[pLast].startPosition = fs[pSpace].startPosition
 				fs[pSpace].startPosition += fs[pLast].size
+				if trace.Enabled() {
+					trace.Step("move", fs[pLast].id, "size", fs[pLast].size, "to", fs[pLast].startPosition, "spaceLeft", fs[pSpace].size)
+				}
 				break
 			}
 		}
@@ -100,13 +109,38 @@ func solvePart2(data []int) int {
 	return checksum
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data) },
+		func() any { return solvePart2(data) }, nil
+}
+
 func main() {
-	data, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(data))
-	fmt.Println("Part 2:", solvePart2(data))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
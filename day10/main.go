@@ -3,10 +3,16 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (grid [][]int, height, width int, err error) {
 	width, height = 0, 0
 	grid = [][]int{}
@@ -82,7 +88,7 @@ func solvePart1(data [][]int, height, width int) int {
 
 	trailheads := findTrailhead(data)
 
-	for _, th := range trailheads {
+	for i, th := range trailheads {
 		curr := make(map[Position]struct{})
 		curr[th] = struct{}{}
 		for alt := 1; alt <= 9; alt++ {
@@ -93,6 +99,9 @@ func solvePart1(data [][]int, height, width int) int {
 			curr = next
 		}
 		result += len(curr)
+		if trace.Enabled() {
+			trace.Step("trailhead", i, "r", th.r, "c", th.c, "score", len(curr), "total", result)
+		}
 	}
 	return result
 }
@@ -112,7 +121,7 @@ func computeNextPositionInTrail(data [][]int, height, width int, pos Position) [
 func solvePart2(data [][]int, height, width int) int {
 	result := 0
 	trailheads := findTrailhead(data)
-	for _, th := range trailheads {
+	for i, th := range trailheads {
 		curr := []Position{th}
 		for alt := 1; alt <= 9; alt++ {
 			next := []Position{}
@@ -122,16 +131,45 @@ func solvePart2(data [][]int, height, width int) int {
 			curr = next
 		}
 		result += len(curr)
+		if trace.Enabled() {
+			trace.Step("trailhead", i, "r", th.r, "c", th.c, "rating", len(curr), "total", result)
+		}
 	}
 	return result
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, height, width, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data, height, width) },
+		func() any { return solvePart2(data, height, width) }, nil
+}
+
 func main() {
-	data, height, width, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
-	fmt.Println("Part 1:", solvePart1(data, height, width))
-	fmt.Println("Part 2:", solvePart2(data, height, width))
+
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
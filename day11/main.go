@@ -3,12 +3,19 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/intmath"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) ([]int64, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -50,32 +57,26 @@ func (index StoneIndex) populate(stones []int64) {
 	}
 }
 
-var powersOf10 = []int64{1, 10, 100, 1_000, 10_000, 100_000, 1_000_000, 10_000_000, 100_000_000, 1_000_000_000}
-
 func splitInteger(num int64) (left, right int64, digits int) {
-	temp := num
-	for temp > 0 {
-		digits++
-		temp /= 10
-	}
-	divisor := int64(powersOf10[digits/2])
-	left = num / divisor
-	right = num % divisor
+	digits = intmath.Digits(num)
+	left, right = intmath.Split(num, digits/2)
 	return
 }
 
-func blink(in []int64) []int64 {
+func blink(in []int64) ([]int64, error) {
 	result := make([]int64, 0, len(in)*2)
 	for _, s := range in {
 		if s == 0 {
 			result = append(result, 1)
 		} else if left, right, digits := splitInteger(s); digits%2 == 0 {
 			result = append(result, left, right)
+		} else if next, ok := intmath.CheckedMul(s, 2024); ok {
+			result = append(result, next)
 		} else {
-			result = append(result, s*2024)
+			return nil, fmt.Errorf("stone %d overflows int64 when multiplied by 2024", s)
 		}
 	}
-	return result
+	return result, nil
 }
 
 func sumValues(index StoneIndex) (sum int64) {
@@ -85,16 +86,18 @@ func sumValues(index StoneIndex) (sum int64) {
 	return
 }
 
-func solve(stones []int64, quantity int64) (result int64) {
+func solve(stones []int64, quantity int64) (result int64, err error) {
 	index := make(StoneIndex)
 	index.populate(stones)
 	iterCount := []int64{5, quantity / 5}
-	for range iterCount[0] {
+	for round := range iterCount[0] {
 		nextIndex := make(StoneIndex)
 		for stone, quant := range index {
 			currStones := []int64{stone}
 			for range iterCount[1] {
-				currStones = blink(currStones)
+				if currStones, err = blink(currStones); err != nil {
+					return 0, err
+				}
 			}
 			currIndex := make(StoneIndex)
 			currIndex.populate(currStones)
@@ -102,16 +105,59 @@ func solve(stones []int64, quantity int64) (result int64) {
 		}
 		index = nextIndex
 		result = sumValues(nextIndex)
+		if trace.Enabled() {
+			trace.Step("round", int(round), "blinks", (round+1)*iterCount[1], "distinct", len(index), "stones", result)
+		}
 	}
 	return
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	stones, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	part := func(blinks int64) func() any {
+		return func() any {
+			count, err := solve(stones, blinks)
+			if err != nil {
+				return err
+			}
+			return count
+		}
+	}
+	return part(25), part(75), nil
+}
+
 func main() {
-	stones, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
-	fmt.Println("Part 1:", solve(stones, 25))
-	fmt.Println("Part 2:", solve(stones, 75))
+
+	for i, part := range []func() any{part1, part2} {
+		answer := part()
+		if err, ok := answer.(error); ok {
+			fmt.Printf("Error solving part %d: %v\n", i+1, err)
+			return
+		}
+		fmt.Printf("Part %d: %v\n", i+1, answer)
+	}
 }
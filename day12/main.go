@@ -3,10 +3,16 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (grid [][]rune, height, width int, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -88,6 +94,9 @@ func solvePart1(grid [][]rune, height, width int) int {
 				perimeter := bfs(grid, height, width, visited, x, y, region)
 				area := len(region)
 				result += area * perimeter
+				if trace.Enabled() {
+					trace.Step("region", x*width+y, "plant", string(grid[x][y]), "area", area, "perimeter", perimeter, "total", result)
+				}
 			}
 		}
 	}
@@ -125,19 +134,47 @@ func solvePart2(grid [][]rune, height, width int) int {
 				area := len(region)
 				edges := countEdges(region)
 				result += area * edges
+				if trace.Enabled() {
+					trace.Step("region", x*width+y, "plant", string(grid[x][y]), "area", area, "sides", edges, "total", result)
+				}
 			}
 		}
 	}
 	return result
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	grid, height, width, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(grid, height, width) },
+		func() any { return solvePart2(grid, height, width) }, nil
+}
+
 func main() {
-	grid, height, width, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(grid, height, width))
-	fmt.Println("Part 2:", solvePart2(grid, height, width))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
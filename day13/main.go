@@ -3,12 +3,18 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (buttonAs, buttonBs, prizes [][2]int, quant int, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -69,6 +75,9 @@ func solvePart1(buttonAs, buttonBs, prizes [][2]int, quantMachines int) int {
 			if a >= 0 && a <= 100 && b >= 0 && b <= 100 {
 				total += 3*a + b
 			}
+			if trace.Enabled() {
+				trace.Step("machine", i, "a", a, "b", b, "total", total)
+			}
 		}
 	}
 	return total
@@ -90,18 +99,46 @@ func solvePart2(buttonAs, buttonBs, prizes [][2]int, quantMachines int) int {
 		if Dx%D == 0 && Dy%D == 0 {
 			a, b := Dx/D, Dy/D
 			total += 3*a + b
+			if trace.Enabled() {
+				trace.Step("machine", i, "a", a, "b", b, "total", total)
+			}
 		}
 	}
 	return total
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	buttonAs, buttonBs, prizes, quantMachines, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(buttonAs, buttonBs, prizes, quantMachines) },
+		func() any { return solvePart2(buttonAs, buttonBs, prizes, quantMachines) }, nil
+}
+
 func main() {
-	buttonAs, buttonBs, prizes, quantMachines, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
 
-	fmt.Println("Part 1:", solvePart1(buttonAs, buttonBs, prizes, quantMachines))
-	fmt.Println("Part 2:", solvePart2(buttonAs, buttonBs, prizes, quantMachines))
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
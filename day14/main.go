@@ -3,10 +3,17 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/progress"
+	"aoc2024/internal/record"
+	"aoc2024/internal/trace"
 )
 
 const (
@@ -18,6 +25,8 @@ type Coord2D struct{ x, y int }
 
 type Robot struct{ id, x, y, vx, vy int }
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func (r *Robot) move() {
 	r.x = (height + r.x + r.vx) % height
 	r.y = (width + r.y + r.vy) % width
@@ -53,24 +62,26 @@ func parseFile(filename string) (robots []Robot, err error) {
 	return robots, nil
 }
 
-func printRobots(robots []Robot) {
-	grid := make([][]int, height)
+func renderRobots(robots []Robot) []string {
+	grid := make([][]rune, height)
 	for i := range grid {
-		grid[i] = make([]int, width)
+		grid[i] = make([]rune, width)
+		for j := range grid[i] {
+			grid[i][j] = ' '
+		}
 	}
 	for _, robot := range robots {
-		grid[robot.x][robot.y]++
+		grid[robot.x][robot.y] = '#'
 	}
-	for _, row := range grid {
-		for _, val := range row {
-			if val != 0 {
-				fmt.Print("#")
-			} else {
-				fmt.Print(" ")
-			}
-		}
-		fmt.Print("\r\n")
+	lines := make([]string, height)
+	for i, row := range grid {
+		lines[i] = string(row)
 	}
+	return lines
+}
+
+func robotsFrame(robots []Robot) []byte {
+	return []byte(strings.Join(renderRobots(robots), "\n"))
 }
 
 func solvePart1(inputRobots []Robot, steps int) int {
@@ -109,7 +120,17 @@ func solvePart2(inputRobots []Robot) int {
 	copy(robots, inputRobots)
 
 	cache := make(map[Coord2D]struct{})
-	
+
+	// Positions repeat after height*width steps, which bounds the search
+	bar := progress.New("steps", height*width)
+	defer bar.Finish()
+
+	rec := record.New("day14-robots")
+	defer rec.Close()
+	if rec != nil {
+		rec.Snapshot(0, robotsFrame(robots))
+	}
+
 	var step int
 	for ; len(cache) != len(robots); step++ {
 		clear(cache)
@@ -117,17 +138,52 @@ func solvePart2(inputRobots []Robot) int {
 			robots[r].move()
 			cache[Coord2D{robots[r].x, robots[r].y}] = struct{}{}
 		}
+		if trace.Enabled() {
+			trace.Step("tick", step+1, "distinct", len(cache), "robots", len(robots))
+		}
+		bar.Add(1)
+		if rec != nil {
+			rec.Snapshot(step+1, robotsFrame(robots))
+		}
+	}
+	if trace.Enabled() {
+		trace.Lines("robots", step, renderRobots(robots))
 	}
-	// printRobots(robots)
 	return step
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	robots, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(robots, 100) },
+		func() any { return solvePart2(robots) }, nil
+}
+
 func main() {
-	robots, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		return
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		return
 	}
-	fmt.Println("Part 1:", solvePart1(robots, 100))
-	fmt.Println("Part 2:", solvePart2(robots))
+
+	fmt.Println("Part 1:", part1())
+	fmt.Println("Part 2:", part2())
 }
@@ -3,10 +3,18 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
+	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/record"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (gridData [][]rune, instructions []rune, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -83,12 +91,18 @@ func (grid *Grid) moveBox(position Point, direction Point) bool {
 	return false // This should never be reached
 }
 
-func (grid *Grid) print(robot Point) {
+func (grid *Grid) render(robot Point) []string {
 	(*grid)[robot.r][robot.c] = '@'
-	for _, row := range *grid {
-		fmt.Println(string(row))
+	lines := make([]string, len(*grid))
+	for i, row := range *grid {
+		lines[i] = string(row)
 	}
 	(*grid)[robot.r][robot.c] = '.'
+	return lines
+}
+
+func (grid *Grid) frame(robot Point) []byte {
+	return []byte(strings.Join(grid.render(robot), "\n"))
 }
 
 func solvePart1(data [][]rune, instructions []rune) int {
@@ -106,8 +120,14 @@ func solvePart1(data [][]rune, instructions []rune) int {
 		}
 	}
 
+	rec := record.New("day15-part1")
+	defer rec.Close()
+	if rec != nil {
+		rec.Snapshot(0, grid.frame(robot))
+	}
+
 	// Process each instruction
-	for _, instruction := range instructions {
+	for step, instruction := range instructions {
 		direction := DIRECTIONS[instruction]
 		position := Point{robot.r + direction.r, robot.c + direction.c}
 
@@ -122,7 +142,15 @@ func solvePart1(data [][]rune, instructions []rune) int {
 				robot = position
 			}
 		}
-		// grid.print(robot)
+		if trace.Enabled() {
+			trace.Step("move", step, "instruction", string(instruction), "r", robot.r, "c", robot.c)
+		}
+		if rec != nil {
+			rec.Snapshot(step+1, grid.frame(robot))
+		}
+	}
+	if trace.Enabled() {
+		trace.Lines("warehouse", len(instructions), grid.render(robot))
 	}
 
 	// GPS
@@ -220,8 +248,14 @@ func solvePart2(data [][]rune, instructions []rune) int {
 			}
 		}
 	}
+	rec := record.New("day15-part2")
+	defer rec.Close()
+	if rec != nil {
+		rec.Snapshot(0, grid.frame(robot))
+	}
+
 	// Process each instruction
-	for _, instruction := range instructions {
+	for step, instruction := range instructions {
 		direction := DIRECTIONS[instruction]
 		position := Point{robot.r + direction.r, robot.c + direction.c}
 
@@ -236,8 +270,15 @@ func solvePart2(data [][]rune, instructions []rune) int {
 				robot = position
 			}
 		}
-		// fmt.Printf("%c\r\n", instruction)
-		// grid.print(robot)
+		if trace.Enabled() {
+			trace.Step("move", step, "instruction", string(instruction), "r", robot.r, "c", robot.c)
+		}
+		if rec != nil {
+			rec.Snapshot(step+1, grid.frame(robot))
+		}
+	}
+	if trace.Enabled() {
+		trace.Lines("warehouse", len(instructions), grid.render(robot))
 	}
 
 	// GPS
@@ -252,13 +293,39 @@ func solvePart2(data [][]rune, instructions []rune) int {
 	return score
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, instructions, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data, instructions) },
+		func() any { return solvePart2(data, instructions) }, nil
+}
+
 func main() {
-	data, instructions, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Part 1: ", solvePart1(data, instructions))
-	fmt.Println("Part 2: ", solvePart2(data, instructions))
+	fmt.Println("Part 1: ", part1())
+	fmt.Println("Part 2: ", part2())
 }
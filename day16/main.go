@@ -4,10 +4,16 @@ package main
 import (
 	"bufio"
 	"container/heap"
+	"flag"
 	"fmt"
 	"os"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (mazeData [][]rune, err error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -80,19 +86,23 @@ func printMaze(maze [][]bool) {
 	}
 }
 
-func printMazePath(data [][]rune, path [][2]int) {
+func renderMazePaths(data [][]rune, paths [][][2]int) []string {
 	height, width := len(data), len(data[0])
 	maze := make([][]rune, height)
 	for x := 0; x < height; x++ {
 		maze[x] = make([]rune, width)
 		copy(maze[x], data[x])
 	}
-	for _, pos := range path {
-		maze[pos[0]][pos[1]] = 'O'
+	for _, path := range paths {
+		for _, pos := range path {
+			maze[pos[0]][pos[1]] = 'O'
+		}
 	}
-	for _, row := range maze {
-		fmt.Println(string(row))
+	lines := make([]string, height)
+	for i, row := range maze {
+		lines[i] = string(row)
 	}
+	return lines
 }
 
 type Item struct {
@@ -213,6 +223,9 @@ func solvePart2(data [][]rune) int {
 
 		// Goal check
 		if current.x == endX && current.y == endY {
+			if trace.Enabled() {
+				trace.Step("goal", len(goalStates), "cost", current.cost, "dir", current.dir)
+			}
 			if minGoalCost == -1 || current.cost < minGoalCost {
 				minGoalCost = current.cost
 				goalStates = []*Item{current} // Reset with the new minimum cost
@@ -250,44 +263,57 @@ func solvePart2(data [][]rune) int {
 	if len(goalStates) == 0 {
 		return 0
 	}
-	// // copy the maze to lay the paths on top
-	// mazeData := make([][]rune, height)
-	// for x := 0; x < height; x++ {
-	// 	mazeData[x] = make([]rune, width)
-	// 	copy(mazeData[x], data[x])
-	// }
-
 	// Reconstruct all paths
 	var allPaths [][][2]int
 	for _, goal := range goalStates {
 		reconstructPaths(goal, [][2]int{}, &allPaths)
 	}
 	for _, path := range allPaths {
-		// // override paths on top of the maze
-		// for _, pos := range path {
-		// 	mazeData[pos[0]][pos[1]] = 'O'
-		// }
 		for _, pos := range path {
 			if _, seen := mapPath[pos]; !seen {
 				mapPath[pos] = true
 			}
 		}
 	}
-	// // print the maze
-	// for _, row := range mazeData {
-	// 	fmt.Println(string(row))
-	// }
+	if trace.Enabled() {
+		trace.Lines("maze", len(allPaths), renderMazePaths(data, allPaths))
+	}
 	return len(mapPath)
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	data, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data) },
+		func() any { return solvePart2(data) }, nil
+}
+
 func main() {
-	data, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("Part 1: ", solvePart1(data))
-	fmt.Println("Part 2: ", solvePart2(data))
+	fmt.Println("Part 1: ", part1())
+	fmt.Println("Part 2: ", part2())
 }
@@ -3,13 +3,21 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/progress"
+	"aoc2024/internal/record"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (registersData [3]int, program []int, err error) {
 	file, errF := os.Open(filename)
 	if errF != nil {
@@ -98,11 +106,30 @@ func (c *Computer) exec(opcode, operand int) {
 }
 
 func (c *Computer) runProgram() {
-	for c.pc <= len(c.program)-2 {
+	c.run(nil)
+}
+
+// run executes the program, snapshotting the machine after every
+// instruction when rec is not nil.
+func (c *Computer) run(rec *record.Recorder) {
+	if rec != nil {
+		rec.Snapshot(0, c.frame())
+	}
+	for step := 0; c.pc <= len(c.program)-2; step++ {
+		if trace.Enabled() {
+			trace.Step("exec", step, "pc", c.pc, "opcode", c.program[c.pc], "operand", c.program[c.pc+1], "ra", c.ra, "rb", c.rb, "rc", c.rc)
+		}
 		c.exec(c.program[c.pc], c.program[c.pc+1])
+		if rec != nil {
+			rec.Snapshot(step+1, c.frame())
+		}
 	}
 }
 
+func (c *Computer) frame() []byte {
+	return fmt.Appendf(nil, "pc=%d\nA=%d\nB=%d\nC=%d\nout=%s\n", c.pc, c.ra, c.rb, c.rc, c.output())
+}
+
 func (c *Computer) reset(ra, rb, rc int) {
 	c.ra, c.rb, c.rc = ra, rb, rc
 	c.pc = 0
@@ -121,31 +148,68 @@ func (c *Computer) output() (out string) {
 
 func solvePart1(data [3]int, program []int) string {
 	computer := Computer{ra: data[0], rb: data[1], rc: data[2], program: program}
-	computer.runProgram()
+	rec := record.New("day17-part1")
+	defer rec.Close()
+	computer.run(rec)
 	return computer.output()
 }
 
 func solvePart2(data [3]int, program []int) int {
 	ra, rb, rc := 0, data[1], data[2]
+	// How many values of A the search tries is not known up front
+	bar := progress.New("candidates", 0)
+	defer bar.Finish()
 	for i := len(program) - 1; i >= 0; i-- {
 		ra <<= 3
 		computer := Computer{ra: ra, rb: rb, rc: rc, program: program}
 		computer.runProgram()
+		bar.Add(1)
 		for !reflect.DeepEqual(computer.out, program[i:]) {
 			ra++
 			computer.reset(ra, rb, rc)
 			computer.runProgram()
+			bar.Add(1)
+		}
+		if trace.Enabled() {
+			trace.Step("digit", len(program)-i, "ra", ra, "out", computer.output())
 		}
 	}
 	return ra
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	registersData, program, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(registersData, program) },
+		func() any { return solvePart2(registersData, program) }, nil
+}
+
 func main() {
-	registersData, program, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("Part 1: ", solvePart1(registersData, program))
-	fmt.Println("Part 2: ", solvePart2(registersData, program))
+
+	fmt.Println("Part 1: ", part1())
+	fmt.Println("Part 2: ", part2())
 }
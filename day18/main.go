@@ -4,11 +4,23 @@ package main
 import (
 	"bufio"
 	"container/heap"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/progress"
+	"aoc2024/internal/trace"
+)
+
+// The sample uses -input sample0.txt -size 7 -bytes 12
+var (
+	inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+	size      = flag.Int("size", 71, "width and height of the memory space")
+	fallen    = flag.Int("bytes", 1024, "number of fallen bytes for part 1")
 )
 
 type Point struct{ y, x int }
@@ -149,7 +161,7 @@ func solve(grid [][]bool, height, width, startX, startY, endX, endY int) int {
 
 func solvePart1(data []Point, height, width, step int) int {
 	startTime := time.Now()
-	defer func(){
+	defer func() {
 		fmt.Printf("Part 1 execution took %s\n", time.Since(startTime))
 	}()
 
@@ -159,30 +171,61 @@ func solvePart1(data []Point, height, width, step int) int {
 
 func solvePart2(data []Point, height, width, minStep int) string {
 	startTime := time.Now()
-	defer func(){
+	defer func() {
 		fmt.Printf("Part 2 execution took %s\n", time.Since(startTime))
 	}()
 
 	step := len(data) - 1
 	cost := -1
+	bar := progress.New("fallen bytes", len(data)-minStep)
+	defer bar.Finish()
 	for cost == -1 && step >= minStep {
 		grid := generateGrid(data, height, width, step)
 		cost = solve(grid, height, width, 0, 0, width-1, height-1)
+		if trace.Enabled() {
+			trace.Step("fallen", step, "cost", cost)
+		}
+		bar.Add(1)
 		step--
 	}
 	step++
 	return fmt.Sprintf("%d,%d", data[step].x, data[step].y)
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	height, width, step := *size, *size, *fallen
+	data, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(data, height, width, step) },
+		func() any { return solvePart2(data, height, width, step) }, nil
+}
+
 func main() {
-	height, width, step := 71, 71, 1024
-	data, err := parseFile("input.txt")
-	// height, width, step := 7, 7, 12
-	// data, err := parseFile("sample0.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("Part 1: ", solvePart1(data, height, width, step))
-	fmt.Println("Part 2: ", solvePart2(data, height, width, step))
+
+	fmt.Println("Part 1: ", part1())
+	fmt.Println("Part 2: ", part2())
 }
@@ -3,12 +3,18 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 	"time"
+
+	"aoc2024/internal/batch"
+	"aoc2024/internal/trace"
 )
 
+var inputFile = flag.String("input", "input.txt", "puzzle input `file`")
+
 func parseFile(filename string) (patterns []string, designs []string, err error) {
 	file, errF := os.Open(filename)
 	if errF != nil {
@@ -55,8 +61,11 @@ func solvePart1(patterns []string, designs []string) int {
 		fmt.Printf("Part 1 execution took %s\n", time.Since(startTime))
 	}()
 	designsCounts := make(map[string]int)
-	for _, design := range designs {
+	for i, design := range designs {
 		designsCounts[design] = solver(&patterns, design)
+		if trace.Enabled() {
+			trace.Step("design", i, "design", design, "ways", designsCounts[design])
+		}
 	}
 	result := 0
 	for _, count := range designsCounts {
@@ -73,8 +82,11 @@ func solvePart2(patterns []string, designs []string) int {
 		fmt.Printf("Part 2 execution took %s\n", time.Since(startTime))
 	}()
 	designsCounts := make(map[string]int)
-	for _, design := range designs {
+	for i, design := range designs {
 		designsCounts[design] = solver(&patterns, design)
+		if trace.Enabled() {
+			trace.Step("design", i, "design", design, "ways", designsCounts[design])
+		}
 	}
 	result := 0
 	for _, count := range designsCounts {
@@ -83,12 +95,39 @@ func solvePart2(patterns []string, designs []string) int {
 	return result
 }
 
+// solvers parses filename and returns the solvers of both parts.
+func solvers(filename string) (part1, part2 func() any, err error) {
+	patterns, designs, err := parseFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func() any { return solvePart1(patterns, designs) },
+		func() any { return solvePart2(patterns, designs) }, nil
+}
+
 func main() {
-	patterns, designs, err := parseFile("input.txt")
+	flag.Parse()
+	closeTrace, err := trace.Setup()
+	if err != nil {
+		fmt.Printf("Error opening trace file: %v\n", err)
+		os.Exit(1)
+	}
+	defer closeTrace()
+
+	if batch.Enabled() {
+		if err := batch.Run(solvers); err != nil {
+			fmt.Printf("Error running batch: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	part1, part2, err := solvers(*inputFile)
 	if err != nil {
 		fmt.Printf("Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("Part 1: ", solvePart1(patterns, designs))
-	fmt.Println("Part 2: ", solvePart2(patterns, designs))
+
+	fmt.Println("Part 1: ", part1())
+	fmt.Println("Part 2: ", part2())
 }
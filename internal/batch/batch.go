@@ -0,0 +1,206 @@
+// Package batch runs one day against many input files and tabulates the
+// answers and timings of both parts.
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	pattern = flag.String("batch", "", "run both parts against every input in `dir` or matching the glob")
+	asJSON  = flag.Bool("json", false, "print batch results as JSON")
+	timeout = flag.Duration("timeout", time.Minute, "give up on parsing or a part after `duration` in batch mode, 0 for no limit")
+)
+
+// Solver parses filename and returns the solvers of both parts. A part
+// that fails returns an error instead of its answer.
+type Solver func(filename string) (part1, part2 func() any, err error)
+
+// Result holds the outcome of one input file. Error is set when parsing
+// or a part failed, panicked or timed out; the parts that did finish keep
+// their answers.
+type Result struct {
+	File  string        `json:"file"`
+	Part1 any           `json:"part1,omitempty"`
+	Part2 any           `json:"part2,omitempty"`
+	Parse time.Duration `json:"parse_ns"`
+	Time1 time.Duration `json:"part1_ns"`
+	Time2 time.Duration `json:"part2_ns"`
+	Error string        `json:"error,omitempty"`
+}
+
+// Enabled reports whether -batch was given. It must be called after
+// flag.Parse.
+func Enabled() bool {
+	return *pattern != ""
+}
+
+// Run solves every input selected by -batch and prints the results on
+// stdout as a table or, with -json, as JSON.
+func Run(solver Solver) error {
+	files, err := inputs(*pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no input files match %q", *pattern)
+	}
+
+	results := make([]Result, len(files))
+	for i, file := range files {
+		results[i] = solve(file, solver)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	return printTable(os.Stdout, results, isTerminal(os.Stdout))
+}
+
+// inputs expands a directory to the text files inside it, or a glob to its
+// matches.
+func inputs(pattern string) ([]string, error) {
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "*.txt")
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func solve(file string, solver Solver) (result Result) {
+	result.File = file
+	var part1, part2 func() any
+	var parseErr error
+
+	elapsed, err := timed(func() { part1, part2, parseErr = solver(file) })
+	result.Parse = elapsed
+	if err == nil {
+		err = parseErr
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	// A failing part 1 does not prevent part 2 from running
+	var errs []error
+	if result.Part1, result.Time1, err = run(part1); err != nil {
+		errs = append(errs, fmt.Errorf("part 1: %w", err))
+	}
+	if result.Part2, result.Time2, err = run(part2); err != nil {
+		errs = append(errs, fmt.Errorf("part 2: %w", err))
+	}
+	if err := errors.Join(errs...); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// run times part and returns its answer, or the error it returned or
+// panicked with.
+func run(part func() any) (answer any, elapsed time.Duration, err error) {
+	elapsed, err = timed(func() { answer = part() })
+	if err != nil {
+		return nil, elapsed, err // part may still be running and writing answer
+	}
+	if partErr, ok := answer.(error); ok {
+		return nil, elapsed, partErr
+	}
+	return answer, elapsed, err
+}
+
+// timed runs f and turns a panic into an error, as well as running past
+// -timeout. Go cannot stop a goroutine, so f then keeps running in the
+// background while the batch moves on.
+func timed(f func()) (elapsed time.Duration, err error) {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+			}
+		}()
+		f()
+		done <- nil
+	}()
+
+	var expired <-chan time.Time
+	if *timeout > 0 {
+		timer := time.NewTimer(*timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case err = <-done:
+	case <-expired:
+		err = fmt.Errorf("timed out after %s", *timeout)
+	}
+	return time.Since(start), err
+}
+
+func printTable(w io.Writer, results []Result, color bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tPART 1\tPART 2\tPARSE\tTIME 1\tTIME 2\tSTATUS")
+	failed := 0
+	for _, r := range results {
+		status := "ok"
+		if r.Error != "" {
+			failed++
+			status = "FAIL: " + strings.SplitN(r.Error, "\n", 2)[0]
+			if color {
+				status = "\033[31m" + status + "\033[0m"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.File, answer(r.Part1), answer(r.Part2),
+			round(r.Parse), round(r.Time1), round(r.Time2), status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		fmt.Fprintf(w, "%d of %d inputs failed\n", failed, len(results))
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Fprintf(w, "\n%s: %s\n", r.File, r.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func answer(v any) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+func round(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Microsecond).String()
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
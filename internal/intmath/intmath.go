@@ -0,0 +1,236 @@
+// Package intmath gathers the integer helpers shared by the days: absolute
+// values, number theory and decimal digit manipulation, with checked
+// arithmetic that reports overflow instead of wrapping around.
+package intmath
+
+import "cmp"
+
+// Signed is the set of signed integer types.
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Unsigned is the set of unsigned integer types.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Integer is the set of all integer types.
+type Integer interface {
+	Signed | Unsigned
+}
+
+// Abs returns the absolute value of x. Like the built-in negation, it
+// returns x itself for the minimum value of T.
+func Abs[T Signed](x T) T {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Min returns the smallest of its arguments.
+func Min[T cmp.Ordered](first T, rest ...T) T {
+	for _, v := range rest {
+		first = min(first, v)
+	}
+	return first
+}
+
+// Max returns the largest of its arguments.
+func Max[T cmp.Ordered](first T, rest ...T) T {
+	for _, v := range rest {
+		first = max(first, v)
+	}
+	return first
+}
+
+func isSigned[T Integer]() bool {
+	var zero T
+	return zero-1 < zero
+}
+
+// CheckedAdd returns a+b and whether it fits in T.
+func CheckedAdd[T Integer](a, b T) (T, bool) {
+	sum := a + b
+	if isSigned[T]() {
+		return sum, (b >= 0) == (sum >= a)
+	}
+	return sum, sum >= a
+}
+
+// CheckedSub returns a-b and whether it fits in T.
+func CheckedSub[T Integer](a, b T) (T, bool) {
+	diff := a - b
+	if isSigned[T]() {
+		return diff, (b >= 0) == (diff <= a)
+	}
+	return diff, b <= a
+}
+
+// CheckedMul returns a*b and whether it fits in T.
+func CheckedMul[T Integer](a, b T) (T, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	prod := a * b
+	if isSigned[T]() && a < 0 && b < 0 && prod < 0 {
+		return prod, false // also catches -1 * minimum, where prod/b == a
+	}
+	return prod, prod/b == a
+}
+
+// GCD returns the greatest common divisor of a and b, always non-negative.
+func GCD[T Integer](a, b T) T {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// LCM returns the least common multiple of a and b, and false when it
+// overflows T.
+func LCM[T Integer](a, b T) (T, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	lcm, ok := CheckedMul(a/GCD(a, b), b)
+	if lcm < 0 {
+		lcm = -lcm
+	}
+	return lcm, ok
+}
+
+// ExtendedGCD returns g = gcd(a, b) together with Bézout coefficients x and
+// y such that a*x + b*y = g.
+func ExtendedGCD[T Signed](a, b T) (g, x, y T) {
+	oldR, r := a, b
+	oldX, x := T(1), T(0)
+	oldY, y := T(0), T(1)
+	for r != 0 {
+		q := oldR / r
+		oldR, r = r, oldR-q*r
+		oldX, x = x, oldX-q*x
+		oldY, y = y, oldY-q*y
+	}
+	if oldR < 0 {
+		return -oldR, -oldX, -oldY
+	}
+	return oldR, oldX, oldY
+}
+
+// Mod returns a modulo m in [0, m) for a positive m, unlike % which keeps
+// the sign of a.
+func Mod[T Signed](a, m T) T {
+	r := a % m
+	if r < 0 {
+		r += m
+	}
+	return r
+}
+
+// ModInverse returns the x in [0, m) such that a*x ≡ 1 (mod m), and false
+// when a and m are not coprime.
+func ModInverse[T Signed](a, m T) (T, bool) {
+	g, x, _ := ExtendedGCD(Mod(a, m), m)
+	if g != 1 {
+		return 0, false
+	}
+	return Mod(x, m), true
+}
+
+// MulMod returns a*b mod m without overflowing, for 0 <= a, b < m.
+func MulMod[T Signed](a, b, m T) T {
+	if prod, ok := CheckedMul(a, b); ok {
+		return prod % m
+	}
+	// Double-and-add keeps every intermediate value below 2m
+	var res T
+	for a %= m; b > 0; b >>= 1 {
+		if b&1 == 1 {
+			res = addMod(res, a, m)
+		}
+		a = addMod(a, a, m)
+	}
+	return res
+}
+
+func addMod[T Signed](a, b, m T) T {
+	if a >= m-b {
+		return a - (m - b)
+	}
+	return a + b
+}
+
+// CRT solves the system x ≡ residues[i] (mod moduli[i]) with the Chinese
+// remainder theorem. The moduli must be positive but need not be coprime.
+// It returns the smallest non-negative solution x and the combined modulus,
+// and false when the system has no solution or the modulus overflows T.
+func CRT[T Signed](residues, moduli []T) (x, m T, ok bool) {
+	x, m = 0, 1
+	for i := range residues {
+		r, n := Mod(residues[i], moduli[i]), moduli[i]
+		g, p, _ := ExtendedGCD(m, n)
+		if (r-x)%g != 0 {
+			return 0, 0, false
+		}
+		lcm, ok := CheckedMul(m/g, n)
+		if !ok {
+			return 0, 0, false
+		}
+		// x + m*k ≡ r (mod n)  =>  k ≡ (r-x)/g * p (mod n/g)
+		k := MulMod(Mod((r-x)/g, n/g), Mod(p, n/g), n/g)
+		x = addMod(x, MulMod(m%lcm, k, lcm), lcm)
+		m = lcm
+	}
+	return x, m, true
+}
+
+// Digits returns the number of decimal digits of n, ignoring its sign. Zero
+// has one digit.
+func Digits[T Integer](n T) int {
+	digits := 1
+	for n /= 10; n != 0; n /= 10 {
+		digits++
+	}
+	return digits
+}
+
+// Pow10 returns 10**n and false when it overflows T.
+func Pow10[T Integer](n int) (T, bool) {
+	p := T(1)
+	for range n {
+		var ok bool
+		if p, ok = CheckedMul(p, 10); !ok {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// Concat appends the decimal digits of b to a, so Concat(12, 345) is
+// 12345. It returns false when the result overflows T. b must not be
+// negative.
+func Concat[T Integer](a, b T) (T, bool) {
+	shift, ok := Pow10[T](Digits(b))
+	if !ok {
+		return 0, false
+	}
+	if a, ok = CheckedMul(a, shift); !ok {
+		return 0, false
+	}
+	return CheckedAdd(a, b)
+}
+
+// Split cuts the last n decimal digits off num, so Split(12345, 2) returns
+// 123 and 45.
+func Split[T Integer](num T, n int) (left, right T) {
+	divisor, ok := Pow10[T](n)
+	if !ok {
+		return 0, num // num has fewer than n digits
+	}
+	return num / divisor, num % divisor
+}
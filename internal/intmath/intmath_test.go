@@ -0,0 +1,299 @@
+package intmath
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCheckedAdd(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+		ok   bool
+	}{
+		{1, 2, 3, true},
+		{math.MaxInt, -1, math.MaxInt - 1, true},
+		{-1, math.MaxInt, math.MaxInt - 1, true},
+		{math.MinInt, 1, math.MinInt + 1, true},
+		{math.MaxInt, math.MinInt, -1, true},
+		{math.MaxInt, 0, math.MaxInt, true},
+		{math.MaxInt, 1, math.MinInt, false},
+		{math.MinInt, -1, math.MaxInt, false},
+		{math.MinInt, math.MinInt, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := CheckedAdd(tt.a, tt.b); ok != tt.ok || ok && got != tt.want {
+			t.Errorf("CheckedAdd(%d, %d) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheckedAddUnsigned(t *testing.T) {
+	if got, ok := CheckedAdd[uint8](200, 55); !ok || got != 255 {
+		t.Errorf("CheckedAdd(200, 55) = %d, %v; want 255, true", got, ok)
+	}
+	if _, ok := CheckedAdd[uint8](200, 56); ok {
+		t.Errorf("CheckedAdd(200, 56) fits in uint8")
+	}
+}
+
+func TestCheckedSub(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+		ok   bool
+	}{
+		{3, 5, -2, true},
+		{-1, math.MaxInt, math.MinInt, true},
+		{math.MaxInt, -1, 0, false},
+		{math.MinInt, 1, 0, false},
+		{0, math.MinInt, 0, false},
+		{-1, math.MinInt, math.MaxInt, true},
+		{math.MinInt, 0, math.MinInt, true},
+		{math.MinInt, math.MinInt, 0, true},
+	}
+	for _, tt := range tests {
+		if got, ok := CheckedSub(tt.a, tt.b); ok != tt.ok || ok && got != tt.want {
+			t.Errorf("CheckedSub(%d, %d) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := CheckedSub[uint](1, 2); ok {
+		t.Errorf("CheckedSub(1, 2) fits in uint")
+	}
+}
+
+func TestCheckedMul(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+		ok   bool
+	}{
+		{6, 7, 42, true},
+		{-6, 7, -42, true},
+		{-6, -7, 42, true},
+		{0, math.MinInt, 0, true},
+		{math.MinInt, 1, math.MinInt, true},
+		{1, math.MinInt, math.MinInt, true},
+		{math.MinInt, -1, 0, false},
+		{-1, math.MinInt, 0, false},
+		{-1, math.MaxInt, -math.MaxInt, true},
+		{math.MaxInt/2 + 1, 2, 0, false},
+		{math.MinInt / 2, 2, math.MinInt, true},
+		{math.MinInt/2 - 1, 2, 0, false},
+		{-(1 << 32), 1 << 32, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := CheckedMul(tt.a, tt.b); ok != tt.ok || ok && got != tt.want {
+			t.Errorf("CheckedMul(%d, %d) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := CheckedMul[int8](-128, -1); ok {
+		t.Errorf("CheckedMul(-128, -1) fits in int8")
+	}
+	if _, ok := CheckedMul[uint8](16, 16); ok {
+		t.Errorf("CheckedMul(16, 16) fits in uint8")
+	}
+}
+
+func TestGCDAndLCM(t *testing.T) {
+	tests := []struct {
+		a, b, gcd, lcm int
+	}{
+		{12, 18, 6, 36},
+		{-12, 18, 6, 36},
+		{12, -18, 6, 36},
+		{0, 5, 5, 0},
+		{7, 0, 7, 0},
+	}
+	for _, tt := range tests {
+		if got := GCD(tt.a, tt.b); got != tt.gcd {
+			t.Errorf("GCD(%d, %d) = %d; want %d", tt.a, tt.b, got, tt.gcd)
+		}
+		if got, ok := LCM(tt.a, tt.b); !ok || got != tt.lcm {
+			t.Errorf("LCM(%d, %d) = %d, %v; want %d, true", tt.a, tt.b, got, ok, tt.lcm)
+		}
+	}
+	if _, ok := LCM(math.MaxInt, math.MaxInt-1); ok {
+		t.Errorf("LCM(MaxInt, MaxInt-1) fits in int")
+	}
+}
+
+func TestExtendedGCD(t *testing.T) {
+	for _, pair := range [][2]int{{240, 46}, {-240, 46}, {17, -5}, {0, 9}, {9, 0}} {
+		a, b := pair[0], pair[1]
+		g, x, y := ExtendedGCD(a, b)
+		if g != GCD(a, b) || a*x+b*y != g {
+			t.Errorf("ExtendedGCD(%d, %d) = %d, %d, %d", a, b, g, x, y)
+		}
+	}
+}
+
+func TestMod(t *testing.T) {
+	tests := []struct{ a, m, want int }{
+		{7, 3, 1},
+		{-7, 3, 2},
+		{-6, 3, 0},
+		{math.MinInt, 7, 6},
+	}
+	for _, tt := range tests {
+		if got := Mod(tt.a, tt.m); got != tt.want {
+			t.Errorf("Mod(%d, %d) = %d; want %d", tt.a, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestModInverse(t *testing.T) {
+	tests := []struct {
+		a, m int
+		want int
+		ok   bool
+	}{
+		{3, 7, 5, true},
+		{-3, 7, 2, true},
+		{10, 7, 5, true},
+		{1, 1, 0, true},
+		{2, 4, 0, false},
+		{0, 7, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ModInverse(tt.a, tt.m); ok != tt.ok || ok && got != tt.want {
+			t.Errorf("ModInverse(%d, %d) = %d, %v; want %d, %v", tt.a, tt.m, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMulMod(t *testing.T) {
+	tests := []struct{ a, b, m, want int }{
+		{3, 4, 5, 2},
+		{0, 4, 5, 0},
+		// (m-1)^2 ≡ 1, although (m-1)^2 overflows
+		{math.MaxInt - 1, math.MaxInt - 1, math.MaxInt, 1},
+		{math.MaxInt - 1, 2, math.MaxInt, math.MaxInt - 2},
+		{1 << 62, 1 << 62, math.MaxInt, 1 << 61},
+	}
+	for _, tt := range tests {
+		if got := MulMod(tt.a, tt.b, tt.m); got != tt.want {
+			t.Errorf("MulMod(%d, %d, %d) = %d; want %d", tt.a, tt.b, tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestCRT(t *testing.T) {
+	tests := []struct {
+		name             string
+		residues, moduli []int
+		x, m             int
+		ok               bool
+	}{
+		{"coprime", []int{2, 3, 2}, []int{3, 5, 7}, 23, 105, true},
+		{"negative residues", []int{-1, -1}, []int{4, 9}, 35, 36, true},
+		{"not coprime", []int{2, 0}, []int{4, 6}, 6, 12, true},
+		{"not coprime, three moduli", []int{1, 3, 13}, []int{6, 10, 15}, 13, 30, true},
+		{"not coprime, no solution", []int{1, 0}, []int{4, 6}, 0, 0, false},
+		{"same modulus, different residues", []int{1, 2}, []int{5, 5}, 0, 0, false},
+		{"empty", nil, nil, 0, 1, true},
+		{"modulus overflows", []int{0, 0}, []int{math.MaxInt, math.MaxInt - 1}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		x, m, ok := CRT(tt.residues, tt.moduli)
+		if ok != tt.ok || ok && (x != tt.x || m != tt.m) {
+			t.Errorf("%s: CRT(%v, %v) = %d, %d, %v; want %d, %d, %v", tt.name, tt.residues, tt.moduli, x, m, ok, tt.x, tt.m, tt.ok)
+		}
+	}
+}
+
+func TestCRTLargeModuli(t *testing.T) {
+	// The combined modulus fits in int64 but the intermediate products do not
+	residues, moduli := []int{1, 2}, []int{1<<31 - 1, 1<<31 + 11}
+	x, m, ok := CRT(residues, moduli)
+	if !ok || m != moduli[0]*moduli[1] || x < 0 || x >= m {
+		t.Fatalf("CRT(%v, %v) = %d, %d, %v", residues, moduli, x, m, ok)
+	}
+	for i, n := range moduli {
+		if x%n != residues[i] {
+			t.Errorf("CRT(%v, %v) = %d, which is %d mod %d", residues, moduli, x, x%n, n)
+		}
+	}
+}
+
+func TestDigits(t *testing.T) {
+	tests := []struct{ n, want int }{
+		{0, 1},
+		{9, 1},
+		{10, 2},
+		{-10, 2},
+		{12345, 5},
+		{math.MaxInt64, 19},
+		{math.MinInt64, 19},
+	}
+	for _, tt := range tests {
+		if got := Digits(tt.n); got != tt.want {
+			t.Errorf("Digits(%d) = %d; want %d", tt.n, got, tt.want)
+		}
+	}
+	if got := Digits[uint64](math.MaxUint64); got != 20 {
+		t.Errorf("Digits(MaxUint64) = %d; want 20", got)
+	}
+}
+
+func TestPow10(t *testing.T) {
+	if got, ok := Pow10[int](18); !ok || got != 1e18 {
+		t.Errorf("Pow10(18) = %d, %v; want 1e18, true", got, ok)
+	}
+	if _, ok := Pow10[int](19); ok {
+		t.Errorf("Pow10(19) fits in int")
+	}
+	if got, ok := Pow10[uint64](19); !ok || got != 1e19 {
+		t.Errorf("Pow10[uint64](19) = %d, %v; want 1e19, true", got, ok)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	tests := []struct {
+		a, b int
+		want int
+		ok   bool
+	}{
+		{12, 345, 12345, true},
+		{12, 0, 120, true},
+		{0, 7, 7, true},
+		{0, 0, 0, true},
+		{922337203685477580, 7, math.MaxInt, true},
+		{922337203685477580, 8, 0, false},
+		{math.MaxInt / 10, 10, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := Concat(tt.a, tt.b); ok != tt.ok || ok && got != tt.want {
+			t.Errorf("Concat(%d, %d) = %d, %v; want %d, %v", tt.a, tt.b, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := Concat[int8](12, 7); !ok || got != 127 {
+		t.Errorf("Concat[int8](12, 7) = %d, %v; want 127, true", got, ok)
+	}
+	if _, ok := Concat[int8](12, 8); ok {
+		t.Errorf("Concat[int8](12, 8) fits in int8")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		num, n      int
+		left, right int
+	}{
+		{12345, 2, 123, 45},
+		{12345, 0, 12345, 0},
+		{12345, 5, 0, 12345},
+		{12345, 7, 0, 12345},
+		{12345, 40, 0, 12345},
+		{1000, 2, 10, 0},
+		{0, 1, 0, 0},
+	}
+	for _, tt := range tests {
+		if left, right := Split(tt.num, tt.n); left != tt.left || right != tt.right {
+			t.Errorf("Split(%d, %d) = %d, %d; want %d, %d", tt.num, tt.n, left, right, tt.left, tt.right)
+		}
+	}
+	if left, right := Split[uint8](255, 3); left != 0 || right != 255 {
+		t.Errorf("Split[uint8](255, 3) = %d, %d; want 0, 255", left, right)
+	}
+}
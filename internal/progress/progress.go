@@ -0,0 +1,131 @@
+// Package progress reports the done/total counts of long-running solver
+// loops, as a live line on a terminal or as periodic log lines otherwise.
+package progress
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var enabled = flag.Bool("progress", false, "report progress of long-running loops on stderr")
+
+const (
+	ttyInterval = 100 * time.Millisecond
+	logInterval = 2 * time.Second
+)
+
+// Reporter tracks one loop. A nil *Reporter is valid and does nothing, which
+// is what New returns when -progress is off.
+type Reporter struct {
+	name  string
+	total int64
+	done  atomic.Int64
+	start time.Time
+	tty   bool
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// New starts reporting a loop of total iterations; total <= 0 means unknown.
+// It must be called after flag.Parse.
+func New(name string, total int) *Reporter {
+	if !*enabled {
+		return nil
+	}
+	now := time.Now()
+	r := &Reporter{name: name, total: int64(total), start: now, last: now}
+	if info, err := os.Stderr.Stat(); err == nil {
+		r.tty = info.Mode()&os.ModeCharDevice != 0
+	}
+	return r
+}
+
+// Add records n more finished iterations. It is safe for concurrent use.
+func (r *Reporter) Add(n int) {
+	if r == nil {
+		return
+	}
+	r.report(r.done.Add(int64(n)), false)
+}
+
+// Set records that done iterations are finished.
+func (r *Reporter) Set(done int) {
+	if r == nil {
+		return
+	}
+	r.done.Store(int64(done))
+	r.report(int64(done), false)
+}
+
+// Finish prints the final state of the loop.
+func (r *Reporter) Finish() {
+	if r == nil {
+		return
+	}
+	r.report(r.done.Load(), true)
+	if r.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (r *Reporter) report(done int64, final bool) {
+	interval := logInterval
+	if r.tty {
+		interval = ttyInterval
+	}
+	if final {
+		r.mu.Lock()
+	} else if !r.mu.TryLock() {
+		return // another goroutine is already reporting
+	}
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if !final && now.Sub(r.last) < interval {
+		return
+	}
+	r.last = now
+	elapsed := now.Sub(r.start)
+
+	if r.tty {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s: %s", r.name, r.line(done, elapsed))
+		return
+	}
+	args := []any{"loop", r.name, "done", done, "elapsed", elapsed.Round(time.Millisecond)}
+	if r.total > 0 {
+		args = append(args, "total", r.total)
+		if eta, ok := r.eta(done, elapsed); ok && !final {
+			args = append(args, "eta", eta)
+		}
+	}
+	slog.Info("progress", args...)
+}
+
+func (r *Reporter) line(done int64, elapsed time.Duration) string {
+	if r.total <= 0 {
+		return fmt.Sprintf("%d done in %s", done, elapsed.Round(time.Millisecond))
+	}
+	line := fmt.Sprintf("%d/%d (%.1f%%) in %s", done, r.total, 100*float64(done)/float64(r.total), elapsed.Round(time.Millisecond))
+	if eta, ok := r.eta(done, elapsed); ok {
+		line += fmt.Sprintf(", ETA %s", eta)
+	}
+	return line
+}
+
+// eta extrapolates the remaining time from the average rate so far.
+func (r *Reporter) eta(done int64, elapsed time.Duration) (time.Duration, bool) {
+	if done <= 0 || done >= r.total {
+		return 0, false
+	}
+	remaining := time.Duration(float64(elapsed) / float64(done) * float64(r.total-done))
+	if remaining < 10*time.Second {
+		return remaining.Round(100 * time.Millisecond), true
+	}
+	return remaining.Round(time.Second), true
+}
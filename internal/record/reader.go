@@ -0,0 +1,173 @@
+package record
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type entry struct {
+	step int
+	key  bool
+	data []byte // full frame for keyframes, encoded changes for deltas
+	size int    // frame length after applying a delta
+}
+
+// Recording is a recording loaded in memory, still delta-encoded.
+type Recording struct {
+	Name    string
+	entries []entry
+}
+
+// Open loads the recording stored in filename.
+func Open(filename string) (*Recording, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a recording: %v", filename, err)
+	}
+	r := bufio.NewReader(gz)
+
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != magic {
+		return nil, fmt.Errorf("%s is not a recording", filename)
+	}
+	name, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("error reading recording name: %v", err)
+	}
+	rec := &Recording{Name: strings.TrimSuffix(name, "\n")}
+
+	for {
+		step, err := binary.ReadUvarint(r)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error reading frame %d: %v", len(rec.entries), err)
+		}
+		e, err := readEntry(r)
+		if err != nil {
+			return nil, fmt.Errorf("error reading frame %d: %v", len(rec.entries), err)
+		}
+		if len(rec.entries) == 0 && !e.key {
+			return nil, fmt.Errorf("recording does not start with a keyframe")
+		}
+		e.step = int(step)
+		rec.entries = append(rec.entries, e)
+	}
+	return rec, nil
+}
+
+func readEntry(r *bufio.Reader) (e entry, err error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return e, err
+	}
+	switch kind {
+	case kindKey:
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return e, err
+		}
+		e.key, e.size, e.data = true, int(size), make([]byte, size)
+		_, err = io.ReadFull(r, e.data)
+		return e, err
+	case kindDelta:
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return e, err
+		}
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return e, err
+		}
+		e.size = int(size)
+		for range count {
+			offset, err := binary.ReadUvarint(r)
+			if err != nil {
+				return e, err
+			}
+			b, err := r.ReadByte()
+			if err != nil {
+				return e, err
+			}
+			e.data = binary.AppendUvarint(e.data, offset)
+			e.data = append(e.data, b)
+		}
+		return e, nil
+	default:
+		return e, fmt.Errorf("unknown frame kind %d", kind)
+	}
+}
+
+// Len returns the number of frames.
+func (rec *Recording) Len() int {
+	return len(rec.entries)
+}
+
+// Step returns the simulation step recorded in frame i.
+func (rec *Recording) Step(i int) int {
+	return rec.entries[i].step
+}
+
+// Frame rebuilds frame i from the nearest keyframe before it.
+func (rec *Recording) Frame(i int) []byte {
+	key := i
+	for !rec.entries[key].key {
+		key--
+	}
+	frame := append([]byte(nil), rec.entries[key].data...)
+	for j := key + 1; j <= i; j++ {
+		frame = apply(frame, rec.entries[j])
+	}
+	return frame
+}
+
+func apply(frame []byte, e entry) []byte {
+	if e.size > len(frame) {
+		frame = append(frame, make([]byte, e.size-len(frame))...)
+	}
+	frame = frame[:e.size]
+	data, pos := e.data, 0
+	for len(data) > 0 {
+		offset, n := binary.Uvarint(data)
+		pos += int(offset)
+		frame[pos] = data[n]
+		data = data[n+1:]
+	}
+	return frame
+}
+
+// Find returns the first frame at or after from whose state satisfies
+// match, or -1. Frames are rebuilt incrementally, so a full scan costs one
+// pass over the recording.
+func (rec *Recording) Find(from int, match func(step int, frame []byte) bool) int {
+	if from < 0 || from >= len(rec.entries) {
+		return -1
+	}
+	frame := rec.Frame(from)
+	for i := from; ; {
+		if match(rec.entries[i].step, frame) {
+			return i
+		}
+		i++
+		if i >= len(rec.entries) {
+			return -1
+		}
+		if rec.entries[i].key {
+			frame = append(frame[:0], rec.entries[i].data...)
+		} else {
+			frame = apply(frame, rec.entries[i])
+		}
+	}
+}
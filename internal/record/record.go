@@ -0,0 +1,130 @@
+// Package record snapshots the state of step-by-step simulations into
+// compact recording files that the replay command can step through.
+//
+// A recording is a gzip stream holding a header line followed by one frame
+// per step. Each frame is either a keyframe with the full state or a delta
+// with the bytes that changed since the previous frame.
+package record
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+var dir = flag.String("record", "", "write step-by-step state recordings into `dir`")
+
+const (
+	magic = "AOCREC1\n"
+
+	// keyEvery bounds how many deltas must be replayed to rebuild a frame.
+	keyEvery = 256
+
+	kindKey   byte = 0
+	kindDelta byte = 1
+)
+
+// Recorder writes one simulation. A nil *Recorder is valid and does
+// nothing, which is what New returns when -record is off, so callers should
+// only build frames when the recorder is non-nil.
+type Recorder struct {
+	file   *os.File
+	gz     *gzip.Writer
+	w      *bufio.Writer
+	prev   []byte
+	frames int
+	err    error
+}
+
+// New starts the recording called name, stored as <dir>/<name>.rec. It must
+// be called after flag.Parse.
+func New(name string) *Recorder {
+	if *dir == "" {
+		return nil
+	}
+	r := &Recorder{}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		r.err = err
+		return r
+	}
+	r.file, r.err = os.Create(filepath.Join(*dir, name+".rec"))
+	if r.err != nil {
+		return r
+	}
+	r.gz = gzip.NewWriter(r.file)
+	r.w = bufio.NewWriter(r.gz)
+	_, r.err = fmt.Fprintf(r.w, "%s%s\n", magic, name)
+	return r
+}
+
+// Snapshot records the state after the given step. The recorder keeps its
+// own copy of frame.
+func (r *Recorder) Snapshot(step int, frame []byte) {
+	if r == nil || r.err != nil {
+		return
+	}
+	var buf []byte
+	buf = binary.AppendUvarint(buf, uint64(step))
+	if delta, ok := r.delta(frame); ok {
+		buf = append(buf, kindDelta)
+		buf = append(buf, delta...)
+	} else {
+		buf = append(buf, kindKey)
+		buf = binary.AppendUvarint(buf, uint64(len(frame)))
+		buf = append(buf, frame...)
+	}
+	_, r.err = r.w.Write(buf)
+	r.prev = append(r.prev[:0], frame...)
+	r.frames++
+}
+
+// delta encodes frame as changes against the previous one, and reports
+// false when a keyframe is due or would be smaller.
+func (r *Recorder) delta(frame []byte) ([]byte, bool) {
+	if r.frames%keyEvery == 0 {
+		return nil, false
+	}
+	var changes, count []byte
+	n, last := 0, 0
+	for i, b := range frame {
+		if i < len(r.prev) && r.prev[i] == b {
+			continue
+		}
+		changes = binary.AppendUvarint(changes, uint64(i-last))
+		changes = append(changes, b)
+		last = i
+		n++
+	}
+	count = binary.AppendUvarint(count, uint64(len(frame)))
+	count = binary.AppendUvarint(count, uint64(n))
+	if len(count)+len(changes) >= len(frame) {
+		return nil, false
+	}
+	return append(count, changes...), true
+}
+
+// Close flushes the recording and reports, and logs, the first error met
+// while writing it.
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	if r.file == nil {
+		slog.Error("recording failed", "err", r.err)
+		return r.err
+	}
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	r.err = errors.Join(r.err, r.gz.Close(), r.file.Close())
+	if r.err != nil {
+		slog.Error("recording failed", "file", r.file.Name(), "err", r.err)
+	}
+	return r.err
+}
@@ -0,0 +1,66 @@
+// Package trace wires the -v and -trace flags shared by every day to a
+// log/slog logger, so solvers can emit debug events without code edits.
+package trace
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+)
+
+var (
+	verbose = flag.Bool("v", false, "emit debug trace events on stderr")
+	output  = flag.String("trace", "", "write debug trace events to `file` (implies -v)")
+)
+
+var enabled bool
+
+// Setup installs the default slog logger according to the -v and -trace
+// flags. It must be called after flag.Parse. The returned function closes
+// the trace file, if any.
+func Setup() (func() error, error) {
+	var w io.Writer = os.Stderr
+	closer := func() error { return nil }
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return closer, err
+		}
+		w, closer = file, file.Close
+	}
+
+	level := slog.LevelInfo
+	if *verbose || *output != "" {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level})))
+	enabled = slog.Default().Enabled(context.Background(), slog.LevelDebug)
+	return closer, nil
+}
+
+// Enabled reports whether debug events are recorded. Hot loops should check
+// it before building event attributes.
+func Enabled() bool {
+	return enabled
+}
+
+// Step emits a debug event for one step of a solver loop.
+func Step(msg string, step int, args ...any) {
+	if !enabled {
+		return
+	}
+	slog.Debug(msg, append([]any{"step", step}, args...)...)
+}
+
+// Lines emits one debug event per line of a rendered picture, such as a grid
+// snapshot, so that it stays readable in the text log.
+func Lines(msg string, step int, lines []string) {
+	if !enabled {
+		return
+	}
+	for row, line := range lines {
+		slog.Debug(msg, "step", step, "row", row, "line", line)
+	}
+}
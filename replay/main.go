@@ -0,0 +1,156 @@
+// replay.go
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"aoc2024/internal/record"
+)
+
+const help = `commands:
+  n [k]         step forward k frames (default 1)
+  p [k]         step backward k frames (default 1)
+  g N           jump to frame N
+  s N           jump to the first frame recorded for simulation step N
+  f REGEX       find the first later frame whose state matches REGEX
+  i             show recording info
+  q             quit`
+
+type Viewer struct {
+	rec *record.Recording
+	pos int
+}
+
+func (v *Viewer) show() {
+	fmt.Printf("-- frame %d/%d, step %d --\n", v.pos, v.rec.Len()-1, v.rec.Step(v.pos))
+	fmt.Println(strings.TrimRight(string(v.rec.Frame(v.pos)), "\n"))
+}
+
+func (v *Viewer) jump(pos int) {
+	v.pos = max(0, min(pos, v.rec.Len()-1))
+	v.show()
+}
+
+func (v *Viewer) find(pattern string, from int) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	found := v.rec.Find(from, func(step int, frame []byte) bool {
+		return re.Match(frame)
+	})
+	if found == -1 {
+		return fmt.Errorf("no frame from %d matches %q", from, pattern)
+	}
+	v.jump(found)
+	return nil
+}
+
+func (v *Viewer) command(line string) (quit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+	arg := func(def int) (int, error) {
+		if len(fields) < 2 {
+			return def, nil
+		}
+		return strconv.Atoi(fields[1])
+	}
+
+	switch fields[0] {
+	case "n", "p":
+		k, err := arg(1)
+		if err != nil {
+			return false, err
+		}
+		if fields[0] == "p" {
+			k = -k
+		}
+		v.jump(v.pos + k)
+	case "g":
+		n, err := arg(v.pos)
+		if err != nil {
+			return false, err
+		}
+		v.jump(n)
+	case "s":
+		n, err := arg(v.rec.Step(v.pos))
+		if err != nil {
+			return false, err
+		}
+		found := v.rec.Find(0, func(step int, frame []byte) bool { return step >= n })
+		if found == -1 {
+			return false, fmt.Errorf("step %d was not recorded", n)
+		}
+		v.jump(found)
+	case "f":
+		if len(fields) < 2 {
+			return false, fmt.Errorf("f needs a pattern")
+		}
+		return false, v.find(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "f")), v.pos+1)
+	case "i":
+		fmt.Printf("%s: %d frames, steps %d to %d\n", v.rec.Name, v.rec.Len(), v.rec.Step(0), v.rec.Step(v.rec.Len()-1))
+	case "q":
+		return true, nil
+	default:
+		fmt.Println(help)
+	}
+	return false, nil
+}
+
+func main() {
+	goTo := flag.Int("frame", 0, "show frame `N` first")
+	find := flag.String("find", "", "start at the first frame matching `regex`")
+	batch := flag.Bool("batch", false, "print the selected frame and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: replay [flags] file.rec\n")
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), help)
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	rec, err := record.Open(flag.Arg(0))
+	if err != nil {
+		fmt.Printf("Error opening recording: %v\n", err)
+		os.Exit(1)
+	}
+	if rec.Len() == 0 {
+		fmt.Println("Recording is empty")
+		return
+	}
+
+	viewer := &Viewer{rec: rec}
+	if *find != "" {
+		if err := viewer.find(*find, *goTo); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	} else {
+		viewer.jump(*goTo)
+	}
+	if *batch {
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		quit, err := viewer.command(scanner.Text())
+		if err != nil {
+			fmt.Println("error:", err)
+		}
+		if quit {
+			return
+		}
+	}
+}